@@ -0,0 +1,66 @@
+package binson
+
+import (
+	"errors"
+	"reflect"
+)
+
+// DecodeAny reads the current value of d, like after NextField, or the
+// whole top-level object at the start of input. OBJECT, ARRAY, INTEGER,
+// DOUBLE, STRING, BYTES and BOOLEAN values are returned as map[string]any,
+// []any, int64, float64, string, []byte and bool. BYTES are copied.
+// Deeply nested input does not cause deep recursion.
+func DecodeAny(d *Decoder) (any, error) {
+	return decodeTree[any, map[string]any, []any](d, d.scalarAny)
+}
+
+// scalarAny returns the current boolean/integer/double/string/bytes
+// value, BYTES are copied.
+func (d *Decoder) scalarAny() any {
+	switch d.ValueType {
+	case Boolean:
+		return d.boolVal
+	case Integer:
+		return d.intVal
+	case Double:
+		return d.floatVal
+	case String:
+		return d.strVal
+	default:
+		return append([]byte{}, d.bytesVal...)
+	}
+}
+
+// EncodeAny writes v to e, as one value of the type DecodeAny returns for
+// it. Maps with string keys are written with the keys in Binson sort order.
+// Other Go values are encoded like by Marshal.
+func EncodeAny(e *Encoder, v any) error {
+	switch v := v.(type) {
+	case nil:
+		return errors.New("binson: cannot encode nil")
+	case map[string]any:
+		// encodeReflect sorts the keys and skips nil values
+		return encodeReflect(e, reflect.ValueOf(v))
+	case []any:
+		e.BeginArray()
+		for _, item := range v {
+			if err := EncodeAny(e, item); err != nil {
+				return err
+			}
+		}
+		e.EndArray()
+	case int64:
+		e.Integer(v)
+	case float64:
+		e.Double(v)
+	case string:
+		e.String(v)
+	case []byte:
+		e.Bytes(v)
+	case bool:
+		e.Bool(v)
+	default:
+		return encodeReflect(e, reflect.ValueOf(v))
+	}
+	return e.Err()
+}
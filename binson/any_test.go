@@ -0,0 +1,76 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeAnyEncodeAny(t *testing.T) {
+	var v = map[string]any{
+		"z": int64(-1),
+		"a": []any{true, 1.5, "s", []byte{1}, map[string]any{}, []any{}},
+		"m": map[string]any{"y": "b", "x": "a"},
+	}
+
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	assert.Nil(t, EncodeAny(e, v))
+	e.Flush()
+
+	// keys are sorted, so strict decoding accepts the output
+	var d = NewBytesDecoderWithOptions(b.Bytes(), DecoderOptions{Strict: true})
+	got, err := DecodeAny(d)
+	assert.Nil(t, err)
+	assert.Equal(t, v, got)
+
+	// the current value of a field
+	d = NewDecoder(bytes.NewReader(b.Bytes()))
+	d.Field("m")
+	got, err = DecodeAny(d)
+	assert.Nil(t, err)
+	assert.Equal(t, v["m"], got)
+	d.Field("z")
+	got, err = DecodeAny(d)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), got)
+	assert.Equal(t, false, d.NextField())
+	assert.Nil(t, d.Err())
+
+	// streamed BYTES payloads are loaded
+	d = NewDecoderWithOptions(bytes.NewReader(b.Bytes()), DecoderOptions{StreamBytes: true})
+	got, err = DecodeAny(d)
+	assert.Nil(t, err)
+	assert.Equal(t, v, got)
+
+	// other Go values are encoded like by Marshal
+	b.Reset()
+	e = NewEncoder(&b)
+	assert.Nil(t, EncodeAny(e, map[string]int{"k": 7}))
+	e.Flush()
+	got, _ = DecodeAny(NewBytesDecoder(b.Bytes()))
+	assert.Equal(t, map[string]any{"k": int64(7)}, got)
+
+	assert.NotNil(t, EncodeAny(e, []any{nil}))
+	var ue *UnsupportedTypeError
+	assert.True(t, errors.As(EncodeAny(e, func() {}), &ue))
+}
+
+func TestDecodeAnyDeepNesting(t *testing.T) {
+	const depth = 100000
+	var raw = "\x40\x14\x01\x61" + strings.Repeat("\x42", depth) + strings.Repeat("\x43", depth) + "\x41"
+
+	got, err := DecodeAny(NewBytesDecoder([]byte(raw)))
+	assert.Nil(t, err)
+	var n = 0
+	for v := got.(map[string]any)["a"]; len(v.([]any)) > 0; v = v.([]any)[0] {
+		n++
+	}
+	assert.Equal(t, depth-1, n)
+
+	_, err = DecodeAny(NewBytesDecoder([]byte(raw[:len(raw)-1])))
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+}
@@ -0,0 +1,95 @@
+package binson
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The Append functions append the encoding of one Binson item to dst and
+// return the extended buffer, like strconv.AppendInt. They are what the
+// Encoder writes, but the caller is responsible for the nesting and the
+// order of the names. For example the object {"a":1} is built by:
+//
+//	b = binson.AppendBegin(b)
+//	b = binson.AppendName(b, "a")
+//	b = binson.AppendInt(b, 1)
+//	b = binson.AppendEnd(b)
+
+// AppendBegin appends OBJECT begin signature to dst
+func AppendBegin(dst []byte) []byte {
+	return append(dst, sigBegin)
+}
+
+// AppendEnd appends OBJECT end signature to dst
+func AppendEnd(dst []byte) []byte {
+	return append(dst, sigEnd)
+}
+
+// AppendBeginArray appends ARRAY begin signature to dst
+func AppendBeginArray(dst []byte) []byte {
+	return append(dst, sigBeginArray)
+}
+
+// AppendEndArray appends ARRAY end signature to dst
+func AppendEndArray(dst []byte) []byte {
+	return append(dst, sigEndArray)
+}
+
+// AppendBool appends boolean value to dst
+func AppendBool(dst []byte, val bool) []byte {
+	if val {
+		return append(dst, sigTrue)
+	}
+	return append(dst, sigFalse)
+}
+
+// AppendInt appends integer value to dst
+func AppendInt(dst []byte, val int64) []byte {
+	return appendIntegerOrLength(dst, sigInteger1, val)
+}
+
+// AppendDouble appends float64 value to dst
+func AppendDouble(dst []byte, val float64) []byte {
+	dst = append(dst, sigDouble)
+	return binary.LittleEndian.AppendUint64(dst, math.Float64bits(val))
+}
+
+// AppendString appends string value to dst
+func AppendString(dst []byte, val string) []byte {
+	dst = appendIntegerOrLength(dst, sigString1, int64(len(val)))
+	return append(dst, val...)
+}
+
+// AppendBytes appends []byte value to dst
+func AppendBytes(dst []byte, val []byte) []byte {
+	dst = appendIntegerOrLength(dst, sigBytes1, int64(len(val)))
+	return append(dst, val...)
+}
+
+// AppendName appends string value as OBJECT item's name to dst
+func AppendName(dst []byte, val string) []byte {
+	return AppendString(dst, val)
+}
+
+/* === private functions === */
+
+// appendIntegerOrLength appends the signature and the minimal encoding
+// of an INTEGER value, or of the length of a STRING/BYTES value.
+func appendIntegerOrLength(dst []byte, baseType byte, val int64) []byte {
+	size := intSize(val)
+	return appendSized(append(dst, baseType|size), size, val)
+}
+
+// appendSized appends val as a little-endian integer of the given size
+func appendSized(dst []byte, size byte, val int64) []byte {
+	switch size {
+	case oneByte:
+		return append(dst, byte(val))
+	case twoBytes:
+		return binary.LittleEndian.AppendUint16(dst, uint16(val))
+	case fourBytes:
+		return binary.LittleEndian.AppendUint32(dst, uint32(val))
+	default:
+		return binary.LittleEndian.AppendUint64(dst, uint64(val))
+	}
+}
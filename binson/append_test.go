@@ -0,0 +1,63 @@
+package binson
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendMatchesEncoder(t *testing.T) {
+	var ints = []int64{0, -1, 127, -128, 128, 32767, -32769, math.MaxInt32 + 1, math.MinInt64, math.MaxInt64}
+
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin()
+	e.Name("a")
+	e.BeginArray()
+	for _, i := range ints {
+		e.Integer(i)
+	}
+	e.Double(-1.5)
+	e.Bool(true)
+	e.Bool(false)
+	e.String("爅웡")
+	e.Bytes(bytes.Repeat([]byte{0xff}, 300))
+	e.EndArray()
+	e.End()
+	e.Flush()
+
+	var got []byte
+	got = AppendBegin(got)
+	got = AppendName(got, "a")
+	got = AppendBeginArray(got)
+	for _, i := range ints {
+		got = AppendInt(got, i)
+	}
+	got = AppendDouble(got, -1.5)
+	got = AppendBool(got, true)
+	got = AppendBool(got, false)
+	got = AppendString(got, "爅웡")
+	got = AppendBytes(got, bytes.Repeat([]byte{0xff}, 300))
+	got = AppendEndArray(got)
+	got = AppendEnd(got)
+
+	assert.Equal(t, b.Bytes(), got)
+}
+
+func TestAppendAllocs(t *testing.T) {
+	var buf = make([]byte, 0, 64)
+	var payload = []byte{1, 2, 3}
+	var allocs = testing.AllocsPerRun(10, func() {
+		b := AppendBegin(buf[:0])
+		b = AppendName(b, "id")
+		b = AppendInt(b, 1<<40)
+		b = AppendName(b, "v")
+		b = AppendBytes(b, payload)
+		b = AppendName(b, "x")
+		b = AppendDouble(b, 0.5)
+		AppendEnd(b)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
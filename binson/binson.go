@@ -3,9 +3,14 @@ package binson
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
 )
 
 // ValueType is type signature for each binson item
@@ -22,6 +27,23 @@ const (
 	Object
 )
 
+var valueTypeNames = [...]string{
+	Boolean: "boolean",
+	Integer: "integer",
+	Double:  "double",
+	String:  "string",
+	Bytes:   "bytes",
+	Array:   "array",
+	Object:  "object",
+}
+
+func (t ValueType) String() string {
+	if int(t) < len(valueTypeNames) {
+		return valueTypeNames[t]
+	}
+	return fmt.Sprintf("ValueType(%d)", uint(t))
+}
+
 // Binson item signatures
 const (
 	sigBegin      byte = 0x40
@@ -56,6 +78,10 @@ const twoTo7 int64 = 128
 const twoTo15 int64 = 32768
 const twoTo31 int64 = 2147483648
 
+// DefaultMaxValueLength is the largest STRING/BYTES length a Decoder accepts
+// unless DecoderOptions.MaxValueLength says otherwise.
+const DefaultMaxValueLength int64 = 10 * 1000000
+
 // Binson Decoder private constants
 const (
 	stateZero = iota
@@ -65,216 +91,911 @@ const (
 	stateEndOfArray
 	stateBeforeObject
 	stateEndOfObject
+	stateBeforeFieldValue
+	stateFieldPending
 )
 
+// DecoderOptions holds the limits and checks enforced by a Decoder.
+// A zero field means no limit, except for MaxValueLength
+// where zero selects DefaultMaxValueLength.
+type DecoderOptions struct {
+	MaxValueLength int64 // max length of a STRING/BYTES value
+	MaxDepth       int   // max nesting depth, the top-level object has depth 1
+	MaxFields      int   // max number of fields in one OBJECT
+	MaxArrayLength int   // max number of values in one ARRAY
+	MaxInputSize   int64 // max total number of input bytes
+
+	// Strict enables validation of all BINSON-SPEC-1 rules: fields must be
+	// sorted and unique, integers and lengths minimally encoded, strings
+	// valid UTF-8, and no input may follow the top-level object. For a
+	// stream decoder the latter means reading until io.EOF after the END,
+	// so Strict is not suitable for several objects sharing one stream.
+	Strict bool
+
+	// NoValue leaves the Value field nil, so that values are not boxed
+	// into an interface. Use the typed accessors, like Int, instead.
+	NoValue bool
+
+	// StreamBytes leaves the payload of BYTES values unread until it is
+	// requested with BytesReader or BytesValue, or skipped by moving on.
+	// Value and Token.Value are nil for non-empty BYTES values. It has no
+	// effect for a decoder created with NewBytesDecoder.
+	StreamBytes bool
+
+	// BufferSize is the size of the buffer used when the input does not
+	// implement io.ByteReader, zero selects the bufio default.
+	BufferSize int
+}
+
+// byteReader is the input interface the Decoder reads from
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// frame is an OBJECT or ARRAY the decoder is currently inside of
+type frame struct {
+	kind  ValueType // Object or Array
+	count int       // number of fields/values read so far
+	name  []byte    // name of the current field (objects only)
+}
+
 // A Decoder represents an Binson parser reading a particular input stream.
 type Decoder struct {
-	r   *bufio.Reader
-	err error
+	r    byteReader
+	buf  *bufio.Reader // own buffering, nil if r is used directly
+	mem  bool          // decoding from data instead of r
+	data []byte
+	err  error
+	off  int64 // number of input bytes consumed, position in data
+	opts DecoderOptions
 
 	Name      string
 	Value     interface{}
 	ValueType ValueType
 
+	boolVal  bool
+	intVal   int64
+	floatVal float64
+	strVal   string
+	bytesVal []byte
+
 	state   int
-	sigByte byte // temp
+	stack   []frame // containers entered, innermost last
+	sigByte byte    // temp
+	scratch [8]byte // integer and double payloads
+	nameBuf []byte  // last field name read
+	strBuf  []byte  // last string value read
+	skipBuf []byte  // discard buffer for readers without Discard
+
+	valStart  int64 // input offset of the current value
+	valEnd    int64 // input offset after the current value
+	pending   int64 // unread payload of the current BYTES value (StreamBytes)
+	streamed  bool  // BytesReader was called for the current value
+	peeked    bool  // peekSig was read ahead by PeekType/PeekName
+	ahead     int64 // length of a field name read ahead by PeekName, not counted yet
+	peekSig   byte
+	capturing bool   // stream input is appended to capture
+	capture   []byte // RawValue buffer for stream input
 }
 
 // NewDecoder creates a new binson parser reading from r.
 // If r does not implement io.ByteReader, NewDecoder will
 // do its own buffering.
+//
+// When r implements io.ByteReader the decoder never reads past the END
+// of the top-level object, so several objects sent back-to-back on one
+// stream can be decoded by creating a new Decoder for each of them.
 func NewDecoder(r io.Reader) *Decoder {
-	d := &Decoder{r: bufio.NewReader(r), state: stateZero}
+	return NewDecoderWithOptions(r, DecoderOptions{})
+}
+
+// NewDecoderWithOptions is like NewDecoder, but enforces the limits in opts.
+func NewDecoderWithOptions(r io.Reader, opts DecoderOptions) *Decoder {
+	d := &Decoder{state: stateZero}
+	d.setOptions(opts)
+	d.setReader(r)
 	return d
 }
 
+// NewDecoderSize is like NewDecoder, but buffers r with a buffer
+// of at least size bytes if r does not implement io.ByteReader.
+func NewDecoderSize(r io.Reader, size int) *Decoder {
+	return NewDecoderWithOptions(r, DecoderOptions{BufferSize: size})
+}
+
+// NewBytesDecoder creates a new binson parser reading from the in-memory
+// input b. No data is copied: BYTES values are returned as sub-slices of b,
+// so b must not be modified while they are in use.
+func NewBytesDecoder(b []byte) *Decoder {
+	return NewBytesDecoderWithOptions(b, DecoderOptions{})
+}
+
+// NewBytesDecoderWithOptions is like NewBytesDecoder, but enforces
+// the limits in opts.
+func NewBytesDecoderWithOptions(b []byte, opts DecoderOptions) *Decoder {
+	d := &Decoder{mem: true, data: b, state: stateZero}
+	d.setOptions(opts)
+	return d
+}
+
+// Buffered returns a reader of the data remaining in the decoder's buffer,
+// i.e. bytes read from the underlying reader but not consumed by the decoder.
+// The reader is valid until the next call to a Decoder method.
+// It is always empty if the underlying reader implements io.ByteReader.
+// For a decoder created with NewBytesDecoder it returns the unread input.
+func (d *Decoder) Buffered() io.Reader {
+	if d.mem {
+		return bytes.NewReader(d.data[d.off:])
+	}
+	if d.buf == nil || d.r != d.buf {
+		return bytes.NewReader(nil)
+	}
+	b, _ := d.buf.Peek(d.buf.Buffered())
+	return bytes.NewReader(b)
+}
+
+// Reset discards the state of the decoder and makes it read a new
+// object from r. The options are kept and the buffers are reused, so a
+// Decoder can be kept in a sync.Pool instead of being created per message.
+func (d *Decoder) Reset(r io.Reader) {
+	d.reset(false, nil)
+	d.setReader(r)
+}
+
+// ResetBytes is like Reset, but makes the decoder read from the
+// in-memory input b, see NewBytesDecoder.
+func (d *Decoder) ResetBytes(b []byte) {
+	d.reset(true, b)
+}
+
+// Err returns the first error encountered by the decoder, or nil.
+// Once an error is recorded, all navigation methods return false.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
 // Field parses until an expected field with the given name is found
-// (without considering fields of inner objects).
+// (without considering fields of inner objects). The values of the
+// fields passed on the way are skipped without being decoded.
+// Since fields are sorted, the search stops at the first field whose name
+// sorts after the given one; that field is then still available for the
+// next call of Field or NextField.
 func (d *Decoder) Field(name string) bool {
-	for d.NextField() {
-		if d.err != nil {
+	if !d.nextFieldName() {
+		return false
+	}
+
+	for {
+		if string(d.nameBuf) == name {
+			d.Name = name
+			return d.readFieldValue()
+		}
+		if string(d.nameBuf) > name {
+			d.state = stateFieldPending
 			return false
 		}
-		if name == d.Name {
-			return true
+		d.skipFieldValue()
+		if !d.readFieldName() {
+			return false
 		}
 	}
+}
 
-	return false
+// Fields extracts several fields of the current object in one forward pass.
+// The names may be given in any order, fn is called for each field found,
+// in the order of the input, with the decoder positioned at the field
+// like after a successful Field call. fn must leave the decoder in the
+// same object. Returns true if all the fields were found.
+func (d *Decoder) Fields(fn func(name string), names ...string) bool {
+	var sorted = append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	var all = true
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			continue
+		}
+		if d.state == stateEndOfObject || !d.Field(name) {
+			all = false
+			continue
+		}
+		fn(name)
+	}
+	return all && d.err == nil
 }
 
 // NextField reads next field, returns true if a field was found and false
-// if end-of-object was reached.
+// if end-of-object was reached or an error occurred (see Err).
 // If  boolean/integer/double/bytes/string was found, the value is also read
 // and is available in `Value` field
 func (d *Decoder) NextField() bool {
-	switch d.state {
-	case stateZero:
-		d.parseBegin()
-	case stateEndOfObject:
-		d.err = fmt.Errorf("reached end-of-object")
+	if !d.nextFieldName() {
 		return false
-	case stateBeforeObject:
-		d.state = stateBeforeField
-		for d.NextField() {
-			if d.err != nil {
-				return false
-			}
-		}
-		d.state = stateBeforeField
-	case stateBeforeArray:
-		d.state = stateBeforeArrayValue
-		for d.NextArrayValue() {
-			if d.err != nil {
-				return false
-			}
-		}
-		d.state = stateBeforeField
 	}
+	d.setName()
+	return d.readFieldValue()
+}
 
-	if d.state != stateBeforeField {
-		d.err = fmt.Errorf("not ready to read a field, state: %v", d.state)
-		return false
+// Int returns the current INTEGER value, a *TypeError is returned
+// if the current value has another type.
+func (d *Decoder) Int() (int64, error) {
+	if err := d.checkScalar(Integer); err != nil {
+		return 0, err
 	}
+	return d.intVal, nil
+}
 
-	typeBeforeName, err := d.r.ReadByte()
-	if err != nil {
-		d.err = fmt.Errorf("abnormal end of input stream detected")
-		return false
+// Float returns the current DOUBLE value, a *TypeError is returned
+// if the current value has another type.
+func (d *Decoder) Float() (float64, error) {
+	if err := d.checkScalar(Double); err != nil {
+		return 0, err
 	}
-	if typeBeforeName == sigEnd {
-		d.state = stateEndOfObject
-		return false
+	return d.floatVal, nil
+}
+
+// Str returns the current STRING value, a *TypeError is returned
+// if the current value has another type.
+func (d *Decoder) Str() (string, error) {
+	if err := d.checkScalar(String); err != nil {
+		return "", err
 	}
-	d.parseFieldName(typeBeforeName)
+	return d.strVal, nil
+}
 
-	typeBeforeValue, err := d.r.ReadByte()
-	if err != nil {
-		d.err = fmt.Errorf("abnormal end of input stream detected")
-		return false
+// BytesValue returns the current BYTES value, a *TypeError is returned
+// if the current value has another type.
+func (d *Decoder) BytesValue() ([]byte, error) {
+	if err := d.checkScalar(Bytes); err != nil {
+		return nil, err
+	}
+	if d.streamed {
+		return nil, fmt.Errorf("%w: BYTES value already read by BytesReader", ErrInvalidState)
+	}
+	if d.pending > 0 {
+		d.loadBytes()
+	}
+	return d.bytesVal, d.err
+}
+
+// Bool returns the current BOOLEAN value, a *TypeError is returned
+// if the current value has another type.
+func (d *Decoder) Bool() (bool, error) {
+	if err := d.checkScalar(Boolean); err != nil {
+		return false, err
 	}
-	d.parseValue(typeBeforeValue, stateBeforeField)
+	return d.boolVal, nil
+}
 
-	return true
+// Skip consumes the current value if it has not been read yet: an OBJECT
+// or ARRAY that NextField/NextArrayValue stopped at, or a field value
+// following a name returned by Token. At the start of input the whole
+// top-level object is skipped. Otherwise Skip does nothing.
+// Skipped values are not decoded and no memory is allocated for them.
+func (d *Decoder) Skip() {
+	if d.err != nil {
+		return
+	}
+
+	switch d.state {
+	case stateZero:
+		d.parseBegin()
+		d.skipTo(0)
+		d.state = stateEndOfObject
+	case stateBeforeObject, stateBeforeArray:
+		d.skipContainer(d.ValueType)
+		d.stateAfterValue()
+	case stateBeforeFieldValue:
+		d.skipFieldValue()
+	}
 }
 
 // NextArrayValue reads next binson ARRAY value,
-// returns true if a field was found and false, if end-of-object was reached.
+// returns true if a field was found and false, if end-of-object was reached
+// or an error occurred (see Err).
 // If boolean/integer/double/bytes/string was found, the value is also read
 // and is available in `Value` field
 func (d *Decoder) NextArrayValue() bool {
-	if d.state == stateBeforeArray {
-		d.state = stateBeforeArrayValue
-		for d.NextArrayValue() {
-			if d.err != nil {
-				return false
-			}
-		}
-		d.state = stateBeforeArrayValue
+	if d.err != nil {
+		return false
 	}
 
-	if d.state == stateBeforeObject {
-		d.state = stateBeforeField
-		for d.NextField() {
-			if d.err != nil {
-				return false
-			}
-		}
+	if d.state == stateBeforeObject || d.state == stateBeforeArray {
+		d.skipContainer(d.ValueType)
 		d.state = stateBeforeArrayValue
 	}
 
+	if d.err != nil {
+		return false
+	}
 	if d.state != stateBeforeArrayValue {
-		d.err = fmt.Errorf("not before array value: %v", d.state)
+		d.fail(fmt.Errorf("%w: not before array value: %v", ErrInvalidState, d.state))
 		return false
 	}
 
-	sig, err := d.r.ReadByte()
-	if err != nil {
-		d.err = fmt.Errorf("abnormal end of input stream detected")
+	sig, ok := d.readByte()
+	if !ok {
 		return false
 	}
 	if sig == sigEndArray {
+		d.pop()
 		d.state = stateEndOfArray
 		return false
 	}
+	if !d.countItem() {
+		return false
+	}
 	d.parseValue(sig, stateBeforeArrayValue)
 
-	return true
+	return d.err == nil
 }
 
 // GoIntoObject navigates decoder inside the expected OBJECT
 func (d *Decoder) GoIntoObject() {
+	if d.err != nil {
+		return
+	}
 	if d.state != stateBeforeObject {
-		d.err = fmt.Errorf("unexpected parser state, not an object field")
+		d.failNotBefore(Object)
 		return
 	}
-	d.state = stateBeforeField
+	if d.push(Object) {
+		d.state = stateBeforeField
+	}
 }
 
 // GoIntoArray navigates decoder inside the expected ARRAY
 func (d *Decoder) GoIntoArray() {
+	if d.err != nil {
+		return
+	}
 	if d.state != stateBeforeArray {
-		d.err = fmt.Errorf("unexpected parser state, not an array field")
+		d.failNotBefore(Array)
 		return
 	}
-	d.state = stateBeforeArrayValue
+	if d.push(Array) {
+		d.state = stateBeforeArrayValue
+	}
 }
 
 // GoUpToObject navigates decoder to the parent OBJECT
+// The parent kind is tracked by the decoder, so GoUpToObject and
+// GoUpToArray both continue in the parent whether it is an OBJECT or an ARRAY.
 func (d *Decoder) GoUpToObject() {
-	if d.state == stateBeforeArrayValue {
-		for d.NextArrayValue() {
-			if d.err != nil {
-				return
-			}
+	if d.goUp() {
+		d.stateAfterValue()
+	}
+}
+
+// GoUpToArray navigates decoder to the parent ARRAY
+// See GoUpToObject.
+func (d *Decoder) GoUpToArray() {
+	if d.goUp() {
+		d.stateAfterValue()
+	}
+}
+
+// Depth returns the number of OBJECT/ARRAY values the decoder is inside of,
+// 1 while reading the fields of the top-level object.
+func (d *Decoder) Depth() int {
+	return len(d.stack)
+}
+
+// Offset returns the number of input bytes consumed so far. A field
+// name looked ahead at by PeekName or PeekType is not included.
+func (d *Decoder) Offset() int64 {
+	return d.off - d.ahead
+}
+
+// Path returns the position of the decoder within the top-level object
+// as the names of the current fields and the indexes of the current
+// array values, e.g. "a.b[3].c". It is empty before the first field.
+func (d *Decoder) Path() string {
+	var b []byte
+	for i := range d.stack {
+		f := &d.stack[i]
+		if f.count == 0 {
+			break
 		}
+		if f.kind == Array {
+			b = append(b, '[')
+			b = strconv.AppendInt(b, int64(f.count-1), 10)
+			b = append(b, ']')
+			continue
+		}
+		if i > 0 {
+			b = append(b, '.')
+		}
+		b = append(b, f.name...)
 	}
+	return string(b)
+}
 
-	if d.state == stateBeforeField {
-		for d.NextField() {
-			if d.err != nil {
-				return
-			}
+/* === private methods === */
+
+func (d *Decoder) setReader(r io.Reader) {
+	if br, ok := r.(byteReader); ok {
+		d.r = br
+		return
+	}
+	if d.buf == nil && d.opts.BufferSize > 0 {
+		d.buf = bufio.NewReaderSize(r, d.opts.BufferSize)
+	} else if d.buf == nil {
+		d.buf = bufio.NewReader(r)
+	} else {
+		d.buf.Reset(r)
+	}
+	d.r = d.buf
+}
+
+// reset clears all decoding state, keeping the options and buffers.
+func (d *Decoder) reset(mem bool, data []byte) {
+	nameBuf := d.nameBuf[:0]
+	if d.mem {
+		// nameBuf points into the previous input
+		nameBuf = nil
+	}
+	*d = Decoder{
+		buf:     d.buf,
+		mem:     mem,
+		data:    data,
+		opts:    d.opts,
+		state:   stateZero,
+		stack:   d.stack[:0],
+		nameBuf: nameBuf,
+		strBuf:  d.strBuf,
+		skipBuf: d.skipBuf,
+		capture: d.capture[:0],
+	}
+}
+
+func (d *Decoder) setOptions(opts DecoderOptions) {
+	if opts.MaxValueLength == 0 {
+		opts.MaxValueLength = DefaultMaxValueLength
+	}
+	d.opts = opts
+}
+
+// goUp skips the rest of the current container,
+// returns false if that failed.
+func (d *Decoder) goUp() bool {
+	if d.err != nil {
+		return false
+	}
+
+	d.skipUp()
+	if d.err == nil && len(d.stack) == 0 {
+		d.fail(fmt.Errorf("%w: no parent container", ErrInvalidState))
+	}
+	return d.err == nil
+}
+
+// skipUp consumes the rest of the current container and leaves it,
+// unless its end was already reached.
+func (d *Decoder) skipUp() {
+	switch d.state {
+	case stateBeforeObject, stateBeforeArray:
+		d.skipContainer(d.ValueType)
+		d.skipTo(len(d.stack) - 1)
+	case stateBeforeFieldValue, stateFieldPending:
+		if d.takeName() {
+			d.skipFieldValue()
+			d.skipTo(len(d.stack) - 1)
 		}
+	case stateBeforeField, stateBeforeArrayValue:
+		d.skipTo(len(d.stack) - 1)
+	case stateEndOfObject, stateEndOfArray:
+	default:
+		d.fail(fmt.Errorf("%w: unexpected parser state: %v", ErrInvalidState, d.state))
 	}
+}
+
+// push enters a container, returns false if MaxDepth is exceeded.
+func (d *Decoder) push(kind ValueType) bool {
+	if d.opts.MaxDepth > 0 && len(d.stack) >= d.opts.MaxDepth {
+		d.fail(&LimitError{Kind: LimitDepth, Max: int64(d.opts.MaxDepth), Value: int64(len(d.stack) + 1), Offset: d.off})
+		return false
+	}
+	// frames are reused to keep their name buffers
+	n := len(d.stack)
+	if n < cap(d.stack) {
+		d.stack = d.stack[:n+1]
+	} else {
+		d.stack = append(d.stack, frame{})
+	}
+	f := &d.stack[n]
+	f.kind, f.count, f.name = kind, 0, f.name[:0]
+	return true
+}
 
-	if d.state != stateEndOfObject && d.state != stateEndOfArray {
-		d.err = fmt.Errorf("unexpected parser state: %v", d.state)
+// pop leaves the current container.
+func (d *Decoder) pop() {
+	d.stack = d.stack[:len(d.stack)-1]
+	if len(d.stack) == 0 && d.opts.Strict {
+		d.checkTrailing()
+	}
+}
+
+// checkTrailing verifies that the input ends after the top-level object.
+func (d *Decoder) checkTrailing() {
+	if d.mem {
+		if d.off != int64(len(d.data)) {
+			d.fail(&SyntaxError{msg: "trailing data after top-level object", Offset: d.off})
+		}
 		return
 	}
 
+	_, err := d.r.ReadByte()
+	switch err {
+	case io.EOF:
+	case nil:
+		d.fail(&SyntaxError{msg: "trailing data after top-level object", Offset: d.off})
+	default:
+		d.fail(err)
+	}
+}
+
+// countItem counts a field/value of the current container,
+// returns false if MaxFields/MaxArrayLength is exceeded.
+func (d *Decoder) countItem() bool {
+	top := &d.stack[len(d.stack)-1]
+	top.count++
+
+	max, kind := d.opts.MaxFields, LimitFields
+	if top.kind == Array {
+		max, kind = d.opts.MaxArrayLength, LimitArrayLength
+	}
+	if max > 0 && top.count > max {
+		d.fail(&LimitError{Kind: kind, Max: int64(max), Value: int64(top.count), Offset: d.off})
+		return false
+	}
+	return true
+}
+
+// nextFieldName makes the name of the next field available in nameBuf,
+// it may already have been read ahead by Field.
+func (d *Decoder) nextFieldName() bool {
+	if d.state == stateFieldPending && d.err == nil {
+		d.state = stateBeforeFieldValue
+		return d.takeName()
+	}
+	return d.beforeField() && d.readFieldName()
+}
+
+// beforeField moves on to where the next field of the current object
+// can be read, returns false if there is no current object.
+func (d *Decoder) beforeField() bool {
+	if d.err != nil {
+		return false
+	}
+
+	switch d.state {
+	case stateZero:
+		d.parseBegin()
+	case stateEndOfObject:
+		d.fail(fmt.Errorf("%w: reached end-of-object", ErrInvalidState))
+		return false
+	case stateBeforeObject, stateBeforeArray:
+		d.skipContainer(d.ValueType)
+		d.state = stateBeforeField
+	case stateBeforeFieldValue:
+		d.skipFieldValue()
+	}
+
+	if d.err != nil {
+		return false
+	}
+	if d.state != stateBeforeField {
+		d.fail(fmt.Errorf("%w: not ready to read a field, state: %v", ErrInvalidState, d.state))
+		return false
+	}
+	return true
+}
+
+// readFieldName reads the next field name into nameBuf,
+// returns false if end-of-object was reached.
+func (d *Decoder) readFieldName() bool {
+	sig, ok := d.readByte()
+	if !ok {
+		return false
+	}
+	if sig == sigEnd {
+		d.pop()
+		d.state = stateEndOfObject
+		return false
+	}
+	if !d.readName(sig) {
+		return false
+	}
+	d.state = stateBeforeFieldValue
+	return d.err == nil
+}
+
+func (d *Decoder) readFieldValue() bool {
+	sig, ok := d.readByte()
+	if !ok {
+		return false
+	}
+	d.parseValue(sig, stateBeforeField)
+	return d.err == nil
+}
+
+// skipFieldValue consumes the value of a field whose name was just read.
+func (d *Decoder) skipFieldValue() {
+	sig, ok := d.readByte()
+	if !ok {
+		return
+	}
+	d.skipValue(sig)
 	d.state = stateBeforeField
 }
 
-// GoUpToArray navigates decoder to the parent ARRAY
-func (d *Decoder) GoUpToArray() {
-	if d.state == stateBeforeArrayValue {
-		for d.NextArrayValue() {
-			if d.err != nil {
+// skipContainer consumes the contents of an OBJECT/ARRAY whose begin
+// signature was just read.
+func (d *Decoder) skipContainer(kind ValueType) {
+	depth := len(d.stack)
+	if d.push(kind) {
+		d.skipTo(depth)
+	}
+}
+
+// skipTo consumes input until the decoder has left all containers
+// deeper than depth. Nesting is tracked on the stack, not by recursion.
+func (d *Decoder) skipTo(depth int) {
+	for len(d.stack) > depth && d.err == nil {
+		sig, ok := d.readByte()
+		if !ok {
+			return
+		}
+
+		if d.stack[len(d.stack)-1].kind == Object {
+			if sig == sigEnd {
+				d.pop()
+				continue
+			}
+			if !d.readName(sig) {
+				return
+			}
+			if sig, ok = d.readByte(); !ok {
+				return
+			}
+		} else {
+			if sig == sigEndArray {
+				d.pop()
+				continue
+			}
+			if !d.countItem() {
 				return
 			}
 		}
+
+		switch sig {
+		case sigBegin:
+			d.push(Object)
+		case sigBeginArray:
+			d.push(Array)
+		default:
+			d.skipScalar(sig)
+		}
+	}
+}
+
+// skipValue consumes the value starting with sig without decoding it.
+func (d *Decoder) skipValue(sig byte) {
+	switch sig {
+	case sigBegin:
+		d.skipContainer(Object)
+	case sigBeginArray:
+		d.skipContainer(Array)
+	default:
+		d.skipScalar(sig)
 	}
+}
 
-	if d.state == stateBeforeField {
-		for d.NextField() {
-			if d.err != nil {
-				return
-			}
+// skipScalar consumes the boolean/integer/double/string/bytes value
+// starting with sig. Only strict mode needs to look at the payload.
+func (d *Decoder) skipScalar(sig byte) {
+	switch sig {
+	case sigFalse, sigTrue:
+	case sigDouble:
+		d.discard(8)
+	case sigInteger1, sigInteger2, sigInteger4, sigInteger8:
+		if d.opts.Strict {
+			d.parseInteger(sig)
+		} else {
+			d.discard(1 << (sig & intLengthMask))
+		}
+	case sigString1, sigString2, sigString4:
+		if d.opts.Strict {
+			d.parseStringBytes(sig)
+		} else if ln := d.parseLength(sig); d.err == nil {
+			d.discard(ln)
+		}
+	case sigBytes1, sigBytes2, sigBytes4:
+		if ln := d.parseLength(sig); d.err == nil {
+			d.discard(ln)
 		}
+	default:
+		d.failSyntax("unexpected type byte: 0x%02x", sig)
 	}
+}
 
-	if d.state != stateEndOfObject && d.state != stateEndOfArray {
-		d.err = fmt.Errorf("unexpected parser state: %v", d.state)
+// fail records err unless an error was already recorded,
+// only the first error is kept. Decoding errors get the current path.
+func (d *Decoder) fail(err error) {
+	if d.err != nil {
 		return
 	}
+	switch e := err.(type) {
+	case *SyntaxError:
+		e.Path = d.Path()
+	case *LimitError:
+		e.Path = d.Path()
+	case *TypeError:
+		e.Path = d.Path()
+	}
+	d.err = err
+}
+
+// failRead records an error returned by the underlying reader.
+func (d *Decoder) failRead(err error) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = &SyntaxError{msg: "unexpected end of input", err: ErrUnexpectedEOF, Offset: d.off}
+	}
+	d.fail(err)
+}
 
-	d.state = stateBeforeArrayValue
+// failSyntax records a syntax error for the byte just read.
+func (d *Decoder) failSyntax(format string, args ...interface{}) {
+	d.fail(&SyntaxError{msg: fmt.Sprintf(format, args...), Offset: d.off - 1})
 }
 
-/* === private methods === */
+func (d *Decoder) failNotBefore(expected ValueType) {
+	if d.ValueType != expected && d.state != stateZero {
+		d.fail(&TypeError{Expected: expected, Got: d.ValueType, Offset: d.off})
+		return
+	}
+	d.fail(fmt.Errorf("%w: not before %v, state: %v", ErrInvalidState, expected, d.state))
+}
+
+// need checks that n more input bytes may be read within MaxInputSize.
+func (d *Decoder) need(n int64) bool {
+	if max := d.opts.MaxInputSize; max > 0 && d.off+n > max {
+		d.fail(&LimitError{Kind: LimitInputSize, Max: max, Value: d.off + n, Offset: d.off})
+		return false
+	}
+	return true
+}
+
+func (d *Decoder) readByte() (byte, bool) {
+	if d.peeked {
+		d.peeked = false
+		d.off++
+		if d.capturing {
+			d.capture = append(d.capture, d.peekSig)
+		}
+		return d.peekSig, true
+	}
+	if d.pending > 0 {
+		d.skipPending()
+	}
+	if !d.need(1) {
+		return 0, false
+	}
+	if d.mem {
+		if d.off >= int64(len(d.data)) {
+			d.failRead(io.EOF)
+			return 0, false
+		}
+		b := d.data[d.off]
+		d.off++
+		return b, true
+	}
+
+	b, err := d.r.ReadByte()
+	if err != nil {
+		d.failRead(err)
+		return 0, false
+	}
+	d.off++
+	if d.capturing {
+		d.capture = append(d.capture, b)
+	}
+	return b, true
+}
+
+// next returns the next n (at most len(scratch)) input bytes,
+// the result is only valid until the next read.
+func (d *Decoder) next(n int) ([]byte, bool) {
+	if d.mem {
+		return d.nextMem(int64(n))
+	}
+
+	buf := d.scratch[:n]
+	return buf, d.readFull(buf)
+}
+
+// readFull fills buf from the stream input.
+func (d *Decoder) readFull(buf []byte) bool {
+	if !d.need(int64(len(buf))) {
+		return false
+	}
+	n, err := io.ReadFull(d.r, buf)
+	d.off += int64(n)
+	if err != nil {
+		d.failRead(err)
+		return false
+	}
+	if d.capturing {
+		d.capture = append(d.capture, buf...)
+	}
+	return true
+}
+
+// discarder is implemented by readers that can skip input, like bufio.Reader
+type discarder interface {
+	Discard(n int) (int, error)
+}
+
+// discard consumes n input bytes without allocating, except when they
+// must be captured for RawValue.
+func (d *Decoder) discard(n int64) {
+	switch {
+	case d.mem:
+		d.nextMem(n)
+	case d.capturing:
+		start := len(d.capture)
+		d.capture = append(d.capture, make([]byte, n)...)
+		d.capturing = false
+		d.readFull(d.capture[start:])
+		d.capturing = true
+	default:
+		if r, ok := d.r.(discarder); ok {
+			if !d.need(n) {
+				return
+			}
+			m, err := r.Discard(int(n))
+			d.off += int64(m)
+			if err != nil {
+				d.failRead(err)
+			}
+			return
+		}
+
+		if d.skipBuf == nil {
+			d.skipBuf = make([]byte, 512)
+		}
+		for n > 0 && d.err == nil {
+			chunk := d.skipBuf
+			if n < int64(len(chunk)) {
+				chunk = chunk[:n]
+			}
+			d.readFull(chunk)
+			n -= int64(len(chunk))
+		}
+	}
+}
+
+// nextMem returns the next n input bytes as a sub-slice of data.
+func (d *Decoder) nextMem(n int64) ([]byte, bool) {
+	if !d.need(n) {
+		return nil, false
+	}
+	if n > int64(len(d.data))-d.off {
+		d.off = int64(len(d.data))
+		d.failRead(io.ErrUnexpectedEOF)
+		return nil, false
+	}
+	b := d.data[d.off : d.off+n : d.off+n]
+	d.off += n
+	return b, true
+}
 
 func (d *Decoder) parseValue(sigByte byte, afterValueState int) {
+	d.sigByte = sigByte
+	d.valStart = d.off - 1
+	d.streamed = false
 	switch sigByte {
 	case sigBegin:
 		d.ValueType = Object
@@ -284,188 +1005,630 @@ func (d *Decoder) parseValue(sigByte byte, afterValueState int) {
 		d.state = stateBeforeArray
 	case sigFalse, sigTrue:
 		d.ValueType = Boolean
-		d.Value = sigByte == sigTrue
+		d.boolVal = sigByte == sigTrue
 		d.state = afterValueState
 	case sigDouble:
-		var d64 float64
 		d.ValueType = Double
-		d.err = binary.Read(d.r, binary.LittleEndian, &d64)
-		d.Value = d64
+		if b, ok := d.next(8); ok {
+			d.floatVal = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
 		d.state = afterValueState
 	case sigInteger1, sigInteger2, sigInteger4, sigInteger8:
 		d.ValueType = Integer
-		d.Value = d.parseInteger(sigByte)
+		d.intVal = d.parseInteger(sigByte)
 		d.state = afterValueState
 	case sigString1, sigString2, sigString4:
 		d.ValueType = String
-		d.Value = d.parseStringBytes(sigByte)
+		d.strVal = string(d.parseStringBytes(sigByte))
 		d.state = afterValueState
 	case sigBytes1, sigBytes2, sigBytes4:
 		d.ValueType = Bytes
-		d.Value = d.parseStringBytes(sigByte)
+		if d.opts.StreamBytes && !d.mem {
+			d.bytesVal = nil
+			if ln := d.parseLength(sigByte); d.err == nil {
+				d.pending = ln
+			}
+		} else {
+			d.bytesVal = d.parseStringBytes(sigByte)
+		}
 		d.state = afterValueState
 	default:
-		d.err = fmt.Errorf("Unexpected type byte: %v", sigByte)
+		d.failSyntax("unexpected type byte: 0x%02x", sigByte)
+	}
+	d.valEnd = d.off + d.pending
+
+	if !d.opts.NoValue && d.err == nil && d.state == afterValueState {
+		if d.pending > 0 {
+			d.Value = nil // not read yet, see StreamBytes
+		} else {
+			d.Value = d.boxValue()
+		}
+	}
+}
+
+// boxValue returns the current boolean/integer/double/string/bytes value
+// as an interface value.
+func (d *Decoder) boxValue() interface{} {
+	switch d.ValueType {
+	case Boolean:
+		return d.boolVal
+	case Integer:
+		return d.intVal
+	case Double:
+		return d.floatVal
+	case String:
+		return d.strVal
+	case Bytes:
+		return d.bytesVal
+	default:
+		return nil
+	}
+}
+
+// checkScalar returns an error if the current value
+// is not a read value of the given type.
+func (d *Decoder) checkScalar(t ValueType) error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.state == stateBeforeObject || d.state == stateBeforeArray {
+		return &TypeError{Expected: t, Got: d.ValueType, Offset: d.off, Path: d.Path()}
 	}
+	if d.valEnd != d.off+d.pending || d.ValueType == Object || d.ValueType == Array {
+		return fmt.Errorf("%w: no current %v value", ErrInvalidState, t)
+	}
+	if d.ValueType != t {
+		return &TypeError{Expected: t, Got: d.ValueType, Offset: d.off, Path: d.Path()}
+	}
+	return nil
+}
+
+// currentScalar returns an error if there is no current scalar value,
+// and loads a BYTES payload left unread because of StreamBytes.
+func (d *Decoder) currentScalar() error {
+	if err := d.checkScalar(d.ValueType); err != nil {
+		return err
+	}
+	if d.pending > 0 && !d.streamed {
+		d.loadBytes()
+	}
+	return d.err
 }
 
 func (d *Decoder) parseFieldName(sigBeforeName byte) {
-	switch sigBeforeName {
+	if d.readName(sigBeforeName) {
+		d.setName()
+	}
+}
+
+// readName reads a field name starting with sig into nameBuf,
+// for a decoder created with NewBytesDecoder without copying it,
+// and records it as the current name of the object.
+func (d *Decoder) readName(sig byte) bool {
+	return d.checkNameSig(sig) && d.countItem() && d.readNameBytes(sig) && d.recordName()
+}
+
+// takeName counts and records a field name read ahead by peekName.
+func (d *Decoder) takeName() bool {
+	if d.ahead == 0 {
+		return d.err == nil
+	}
+	d.ahead = 0
+	return d.countItem() && d.recordName()
+}
+
+func (d *Decoder) checkNameSig(sig byte) bool {
+	switch sig {
 	case sigString1, sigString2, sigString4:
-		d.Name = d.parseStringBytes(sigBeforeName).(string)
-	default:
-		d.err = fmt.Errorf("unexpected type: %v", sigBeforeName)
+		return true
 	}
+	d.failSyntax("expected field name, got type byte: 0x%02x", sig)
+	return false
+}
+
+// readNameBytes reads the length and bytes of a field name into nameBuf.
+func (d *Decoder) readNameBytes(sig byte) bool {
+	ln := d.parseLength(sig)
+	if d.err != nil {
+		return false
+	}
+	if d.mem {
+		var ok bool
+		if d.nameBuf, ok = d.nextMem(ln); !ok {
+			return false
+		}
+	} else {
+		if int64(cap(d.nameBuf)) < ln {
+			d.nameBuf = make([]byte, ln)
+		}
+		d.nameBuf = d.nameBuf[:ln]
+		if !d.readFull(d.nameBuf) {
+			return false
+		}
+	}
+
+	if d.opts.Strict && !utf8.Valid(d.nameBuf) {
+		d.fail(&SyntaxError{msg: "invalid UTF-8 in field name", Offset: d.off - ln})
+		return false
+	}
+	return true
+}
+
+// setName makes the name in nameBuf the current field name.
+func (d *Decoder) setName() {
+	d.Name = string(d.nameBuf)
 }
 
 func (d *Decoder) parseBegin() {
-	d.err = binary.Read(d.r, binary.LittleEndian, &d.sigByte)
-	if d.sigByte != sigBegin {
-		d.err = fmt.Errorf("Expected BEGIN, got: %v", d.sigByte)
+	sig, ok := d.readByte()
+	if !ok {
 		return
 	}
-	d.state = stateBeforeField
+	if sig != sigBegin {
+		d.failSyntax("expected BEGIN, got: 0x%02x", sig)
+		return
+	}
+	if d.push(Object) {
+		d.state = stateBeforeField
+	}
 }
 
-func (d *Decoder) parseStringBytes(sigByte byte) interface{} {
+// parseLength reads the length of a STRING/BYTES value and checks it
+// against MaxValueLength.
+func (d *Decoder) parseLength(sigByte byte) int64 {
 	ln := d.parseInteger(sigByte)
+	if d.err != nil {
+		return -1
+	}
 
 	if ln < 0 {
-		d.err = fmt.Errorf("Bad string/bytes length: %v", ln)
-		return nil
+		d.fail(&SyntaxError{msg: fmt.Sprintf("bad string/bytes length: %v", ln), Offset: d.off})
+		return -1
 	}
 
-	if ln > 10*1000000 {
-		d.err = fmt.Errorf("String/Bytes length too big: %v", ln)
+	if ln > d.opts.MaxValueLength {
+		d.fail(&LimitError{Kind: LimitValueLength, Max: d.opts.MaxValueLength, Value: ln, Offset: d.off})
+		return -1
+	}
+	return ln
+}
+
+func (d *Decoder) parseStringBytes(sigByte byte) []byte {
+	ln := d.parseLength(sigByte)
+	if d.err != nil {
 		return nil
 	}
 
-	var buf = make([]byte, ln)
-	n, err := io.ReadFull(d.r, buf)
+	var buf []byte
+	if d.mem {
+		var ok bool
+		if buf, ok = d.nextMem(ln); !ok {
+			return nil
+		}
+	} else {
+		if sigByte < sigBytes1 {
+			// a string is copied by the caller, reuse the buffer
+			if int64(cap(d.strBuf)) < ln {
+				d.strBuf = make([]byte, ln)
+			}
+			buf = d.strBuf[:ln]
+		} else {
+			buf = make([]byte, ln)
+		}
+		if !d.readFull(buf) {
+			return nil
+		}
+	}
 
-	if int64(n) < ln || err != nil {
-		d.err = fmt.Errorf("abnormal end of input stream detected")
+	if d.opts.Strict && sigByte < sigBytes1 && !utf8.Valid(buf) {
+		d.fail(&SyntaxError{msg: "invalid UTF-8 in string", Offset: d.off - ln})
 		return nil
 	}
+	return buf
+}
 
-	if sigByte >= sigBytes1 {
-		return buf
+// recordName copies the name in nameBuf to the current object,
+// in strict mode after verifying that it sorts after the previous one.
+func (d *Decoder) recordName() bool {
+	top := &d.stack[len(d.stack)-1]
+	if d.opts.Strict && top.count > 1 {
+		switch c := bytes.Compare(d.nameBuf, top.name); {
+		case c == 0:
+			d.fail(&SyntaxError{msg: fmt.Sprintf("duplicate field name %q", d.nameBuf), Offset: d.off})
+			return false
+		case c < 0:
+			d.fail(&SyntaxError{msg: fmt.Sprintf("field %q out of order after %q", d.nameBuf, top.name), Offset: d.off})
+			return false
+		}
 	}
-	return string(buf)
+	top.name = append(top.name[:0], d.nameBuf...)
+	return true
 }
 
 func (d *Decoder) parseInteger(sigByte byte) int64 {
-	switch sigByte & intLengthMask {
+	var size = sigByte & intLengthMask
+	var b, ok = d.next(1 << size)
+	if !ok {
+		return -1
+	}
+
+	var val int64
+	switch size {
 	case oneByte:
-		var i1 int8
-		d.err = binary.Read(d.r, binary.LittleEndian, &i1)
-		return int64(i1)
+		val = int64(int8(b[0]))
 	case twoBytes:
-		var i2 int16
-		d.err = binary.Read(d.r, binary.LittleEndian, &i2)
-		return int64(i2)
+		val = int64(int16(binary.LittleEndian.Uint16(b)))
 	case fourBytes:
-		var i4 int32
-		d.err = binary.Read(d.r, binary.LittleEndian, &i4)
-		return int64(i4)
+		val = int64(int32(binary.LittleEndian.Uint32(b)))
 	case eightBytes:
-		var i8 int64
-		d.err = binary.Read(d.r, binary.LittleEndian, &i8)
-		return int64(i8)
+		val = int64(binary.LittleEndian.Uint64(b))
+	}
+
+	if d.opts.Strict && size != intSize(val) {
+		d.fail(&SyntaxError{msg: fmt.Sprintf("non-minimal encoding of integer/length %d", val), Offset: d.off})
+	}
+	return val
+}
+
+// intSize returns the smallest integer size the value fits in
+func intSize(val int64) byte {
+	switch {
+	case val >= -twoTo7 && val < twoTo7:
+		return oneByte
+	case val >= -twoTo15 && val < twoTo15:
+		return twoBytes
+	case val >= -twoTo31 && val < twoTo31:
+		return fourBytes
 	default:
-		d.err = fmt.Errorf("never happens")
-		return -1
+		return eightBytes
 	}
 }
 
+// EncoderOptions holds the settings of an Encoder.
+type EncoderOptions struct {
+	// BufferSize is the size of the output buffer,
+	// zero selects the bufio default.
+	BufferSize int
+
+	// NoValidation turns off the checks of the nesting of Begin/End,
+	// BeginArray/EndArray, Name and value calls, for raw speed.
+	NoValidation bool
+}
+
 // An Encoder writes binson data to an output stream.
+//
+// Unless disabled with EncoderOptions.NoValidation, the Encoder checks that
+// names and values are written in a valid order: a Name only directly inside
+// an object and followed by exactly one value, every End/EndArray closing
+// a matching Begin/BeginArray, and only objects at the top level, where
+// several of them may be written back-to-back on one stream. A call
+// that breaks these rules writes nothing and records an error wrapping
+// ErrInvalidNesting. The first error, including errors writing to the
+// output stream, is kept and all writes after it are skipped, see Err
+// and Flush.
 type Encoder struct {
-	w   *bufio.Writer
-	err error
+	w       *bufio.Writer // nil for a size-counting encoder
+	base    encoderOutput // w, or size for a size-counting encoder
+	out     encoderOutput // base, or sortBuf inside a sorted object
+	err     error
+	opts    EncoderOptions
+	stack   []encoderFrame // containers begun, innermost last
+	scratch [9]byte        // integer/length and double encodings
+
+	wrote bool // a top-level object was begun
+
+	sorting int           // number of sorted objects begun
+	sortBuf bytes.Buffer  // output of the outermost sorted object
+	fields  []sortedField // fields of the sorted objects begun
+	sortTmp []byte
+
+	size sizeCounter // output of a size-counting encoder
+}
+
+// encoderOutput is implemented by bufio.Writer, bytes.Buffer and sizeCounter
+type encoderOutput interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// encoderFrame is an OBJECT or ARRAY the encoder is writing
+type encoderFrame struct {
+	kind   ValueType // Object or Array
+	named  bool      // a field name was written, its value was not
+	sorted bool      // object begun with BeginSorted
+	start  int       // sorted object: offset of the fields in sortBuf
+	first  int       // sorted object: index of the first field in fields
 }
 
 // NewEncoder returns a new encoder that writes to w, with buffering
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: bufio.NewWriter(w)}
+	return NewEncoderWithOptions(w, EncoderOptions{})
+}
+
+// NewEncoderSize is like NewEncoder, with a buffer of at least size bytes.
+func NewEncoderSize(w io.Writer, size int) *Encoder {
+	return NewEncoderWithOptions(w, EncoderOptions{BufferSize: size})
+}
+
+// NewEncoderWithOptions is like NewEncoder, with the settings in opts.
+func NewEncoderWithOptions(w io.Writer, opts EncoderOptions) *Encoder {
+	e := &Encoder{opts: opts}
+	if opts.BufferSize > 0 {
+		e.w = bufio.NewWriterSize(w, opts.BufferSize)
+	} else {
+		e.w = bufio.NewWriter(w)
+	}
+	e.base = e.w
+	e.out = e.base
+	return e
+}
+
+// Reset discards any unflushed data, the error and the open containers,
+// and makes the encoder write to w reusing its buffer, e.g. for keeping
+// Encoders in a sync.Pool. A size-counting encoder ignores w and
+// restarts counting from zero.
+func (e *Encoder) Reset(w io.Writer) {
+	if e.w != nil {
+		e.w.Reset(w)
+	}
+	e.size = 0
+	e.out = e.base
+	e.err = nil
+	e.stack = e.stack[:0]
+	e.wrote = false
+	e.sorting = 0
+	e.sortBuf.Reset()
+	e.fields = e.fields[:0]
+}
+
+// Err returns the first error recorded by the encoder, or nil. Errors
+// writing to the output stream are recorded too, but as output is
+// buffered they may only show up at a later write or at Flush.
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Done returns an error if an OBJECT or ARRAY is still open or, unless
+// EncoderOptions.NoValidation is set, if no object was written at all,
+// otherwise the error recorded by the encoder, if any.
+func (e *Encoder) Done() error {
+	switch {
+	case e.err != nil:
+	case len(e.stack) > 0:
+		e.err = fmt.Errorf("%w: %v not closed", ErrInvalidNesting, e.stack[len(e.stack)-1].kind)
+	case !e.wrote && !e.opts.NoValidation:
+		e.err = fmt.Errorf("%w: no object written", ErrInvalidNesting)
+	}
+	return e.err
 }
 
-// Flush encoder buffers
-func (e *Encoder) Flush() {
-	e.w.Flush()
+// Flush writes any buffered data to the output stream and returns the
+// first error recorded by the encoder, see Err.
+func (e *Encoder) Flush() error {
+	if e.w != nil {
+		if err := e.w.Flush(); e.err == nil {
+			e.err = err
+		}
+	}
+	return e.err
 }
 
-// Begin writes OBJECT begin signature to output stream
+// Begin writes OBJECT begin signature to output stream.
+// Inside an object begun with BeginSorted, it works like BeginSorted.
 func (e *Encoder) Begin() {
-	e.err = e.w.WriteByte(sigBegin)
+	if !e.object() {
+		return
+	}
+	if e.sorting > 0 {
+		e.beginSorted()
+		return
+	}
+	e.push(Object)
+	e.writeByte(sigBegin)
 }
 
 // End writes OBJECT end signature to output stream
 func (e *Encoder) End() {
-	e.err = e.w.WriteByte(sigEnd)
+	if f, ok := e.pop(Object); ok && !f.sorted {
+		e.writeByte(sigEnd)
+	}
 }
 
 // BeginArray writes ARRAY begin signature to output stream
 func (e *Encoder) BeginArray() {
-	e.err = e.w.WriteByte(sigBeginArray)
+	if e.inner() {
+		e.push(Array)
+		e.writeByte(sigBeginArray)
+	}
 }
 
 // EndArray writes ARRAY end signature to output stream
 func (e *Encoder) EndArray() {
-	e.err = e.w.WriteByte(sigEndArray)
+	if f, ok := e.pop(Array); ok && !f.sorted {
+		e.writeByte(sigEndArray)
+	}
 }
 
 // Bool writes specified boolean value to output stream
 func (e *Encoder) Bool(val bool) {
+	if !e.inner() {
+		return
+	}
 	var sig = sigTrue
 	if !val {
 		sig = sigFalse
 	}
-	e.err = e.w.WriteByte(sig)
+	e.writeByte(sig)
 }
 
 // Integer writes specified integer value to output stream
 func (e *Encoder) Integer(val int64) {
-	e.writeIntegerOrLength(sigInteger1, val)
+	if e.inner() {
+		e.writeIntegerOrLength(sigInteger1, val)
+	}
 }
 
 // Double writes float64 value to output stream
 func (e *Encoder) Double(val float64) {
-	e.w.WriteByte(sigDouble)
-	binary.Write(e.w, binary.LittleEndian, val)
+	if !e.inner() {
+		return
+	}
+	e.write(AppendDouble(e.scratch[:0], val))
 }
 
 // String writes string value to output stream
 func (e *Encoder) String(val string) {
-	e.writeIntegerOrLength(sigString1, int64(len(val)))
-	e.w.WriteString(val)
+	if e.inner() {
+		e.writeString(val)
+	}
 }
 
 // Bytes writes []byte value to output stream
 func (e *Encoder) Bytes(val []byte) {
+	if !e.inner() {
+		return
+	}
 	e.writeIntegerOrLength(sigBytes1, int64(len(val)))
-	e.w.Write(val)
+	e.write(val)
 }
 
 // Name writes string value as OBJECT item's name to output stream
 func (e *Encoder) Name(val string) {
-	e.String(val)
+	if e.name(val) {
+		e.writeString(val)
+	}
 }
 
 /* === private methods === */
 
-func (e *Encoder) writeIntegerOrLength(baseType byte, val int64) {
+// inner is like value, for a value that is not an OBJECT,
+// which is only valid inside an object or array.
+func (e *Encoder) inner() bool {
+	if e.err == nil && !e.opts.NoValidation && len(e.stack) == 0 {
+		e.err = fmt.Errorf("%w: value outside of object", ErrInvalidNesting)
+		return false
+	}
+	return e.value()
+}
+
+// object is like value, for an OBJECT, which is valid at the top level.
+func (e *Encoder) object() bool {
+	if !e.value() {
+		return false
+	}
+	if len(e.stack) == 0 {
+		e.wrote = true
+	}
+	return true
+}
+
+// value checks that a value may be written next, returns false if not.
+func (e *Encoder) value() bool {
+	if e.err != nil {
+		return false
+	}
+	if e.opts.NoValidation || len(e.stack) == 0 {
+		return true
+	}
+
+	top := &e.stack[len(e.stack)-1]
+	if top.kind == Object {
+		if !top.named {
+			e.err = fmt.Errorf("%w: value without a name in object", ErrInvalidNesting)
+			return false
+		}
+		top.named = false
+	}
+	return true
+}
+
+// name checks that a field name may be written next, returns false if not.
+func (e *Encoder) name(val string) bool {
+	if e.err != nil {
+		return false
+	}
+	if e.sorting > 0 && len(e.stack) > 0 && e.stack[len(e.stack)-1].sorted {
+		e.addField(val)
+	}
+	if e.opts.NoValidation {
+		return true
+	}
+
 	switch {
-	case val >= -twoTo7 && val < twoTo7:
-		e.w.WriteByte(baseType | oneByte)
-		binary.Write(e.w, binary.LittleEndian, byte(val))
-	case val >= -twoTo15 && val < twoTo15:
-		e.w.WriteByte(baseType | twoBytes)
-		binary.Write(e.w, binary.LittleEndian, int16(val))
-	case val >= -twoTo31 && val < twoTo31:
-		e.w.WriteByte(baseType | fourBytes)
-		binary.Write(e.w, binary.LittleEndian, int32(val))
+	case len(e.stack) == 0:
+		e.err = fmt.Errorf("%w: name outside of object", ErrInvalidNesting)
+	case e.stack[len(e.stack)-1].kind != Object:
+		e.err = fmt.Errorf("%w: name inside array", ErrInvalidNesting)
+	case e.stack[len(e.stack)-1].named:
+		e.err = fmt.Errorf("%w: name without a value", ErrInvalidNesting)
+	default:
+		e.stack[len(e.stack)-1].named = true
+	}
+	return e.err == nil
+}
+
+func (e *Encoder) push(kind ValueType) {
+	e.stack = append(e.stack, encoderFrame{kind: kind})
+}
+
+// pop leaves the innermost container after checking that it is of the
+// given kind and may be ended, returns false if not. A sorted object is
+// written out with its end signature, whatever the kind.
+func (e *Encoder) pop(kind ValueType) (encoderFrame, bool) {
+	if e.err != nil {
+		return encoderFrame{}, false
+	}
+
+	var f encoderFrame
+	switch {
+	case e.opts.NoValidation:
+		if len(e.stack) > 0 {
+			f = e.stack[len(e.stack)-1]
+			e.stack = e.stack[:len(e.stack)-1]
+		}
+	case len(e.stack) == 0:
+		e.err = fmt.Errorf("%w: end of %v without begin", ErrInvalidNesting, kind)
+	case e.stack[len(e.stack)-1].kind != kind:
+		e.err = fmt.Errorf("%w: end of %v inside %v", ErrInvalidNesting, kind, e.stack[len(e.stack)-1].kind)
+	case e.stack[len(e.stack)-1].named:
+		e.err = fmt.Errorf("%w: name without a value", ErrInvalidNesting)
 	default:
-		e.w.WriteByte(baseType | eightBytes)
-		binary.Write(e.w, binary.LittleEndian, int64(val))
+		f = e.stack[len(e.stack)-1]
+		e.stack = e.stack[:len(e.stack)-1]
+	}
+	if e.err != nil {
+		return f, false
+	}
+	if f.sorted {
+		e.endSorted(f)
+	}
+	return f, true
+}
+
+func (e *Encoder) writeString(val string) {
+	e.writeIntegerOrLength(sigString1, int64(len(val)))
+	if e.err == nil {
+		_, e.err = e.out.WriteString(val)
+	}
+}
+
+func (e *Encoder) writeIntegerOrLength(baseType byte, val int64) {
+	// encode into scratch, binary.Write would allocate
+	e.write(appendIntegerOrLength(e.scratch[:0], baseType, val))
+}
+
+// writeByte writes b to the output unless an error was recorded,
+// and records the error of the output, if any.
+func (e *Encoder) writeByte(b byte) {
+	if e.err == nil {
+		e.err = e.out.WriteByte(b)
+	}
+}
+
+// write is like writeByte, for a slice of bytes.
+func (e *Encoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.out.Write(p)
 	}
 }
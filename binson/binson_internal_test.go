@@ -86,7 +86,7 @@ func TestTableInts(t *testing.T) {
 		var b bytes.Buffer
 
 		// test Encoder
-		enc := NewEncoder(&b)
+		enc := NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
 		enc.Integer(record.val)
 		enc.Flush()
 		if !bytes.Equal(record.raw, b.Bytes()) {
@@ -111,7 +111,7 @@ func TestTableBooleans(t *testing.T) {
 		var b bytes.Buffer
 
 		// test Encoder
-		enc := NewEncoder(&b)
+		enc := NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
 		enc.Bool(record.val)
 		enc.Flush()
 		if !bytes.Equal(record.raw, b.Bytes()) {
@@ -136,7 +136,7 @@ func TestTableDoubles(t *testing.T) {
 		var b bytes.Buffer
 
 		// test Encoder
-		enc := NewEncoder(&b)
+		enc := NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
 		enc.Double(record.val)
 		enc.Flush()
 		if !bytes.Equal(record.raw, b.Bytes()) && !math.IsNaN(record.val) {
@@ -161,7 +161,7 @@ func TestTableStrings(t *testing.T) {
 		var b bytes.Buffer
 
 		// test Encoder
-		enc := NewEncoder(&b)
+		enc := NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
 		enc.String(record.val)
 		enc.Flush()
 		if !bytes.Equal(record.raw, b.Bytes()) {
@@ -186,7 +186,7 @@ func TestTableBytes(t *testing.T) {
 		var b bytes.Buffer
 
 		// test Encoder
-		enc := NewEncoder(&b)
+		enc := NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
 		enc.Bytes(record.val)
 		enc.Flush()
 		if !bytes.Equal(record.raw, b.Bytes()) {
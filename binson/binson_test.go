@@ -3,6 +3,9 @@ package binson
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,7 +28,7 @@ func TestEncoderEmptyBinsonObject(t *testing.T) {
 func TestEncoderEmptyBinsonArray(t *testing.T) {
 	var exp = []byte("\x42\x43") // []
 	var b bytes.Buffer
-	var e = NewEncoder(&b)
+	var e = NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
 
 	e.BeginArray()
 	e.EndArray()
@@ -476,3 +479,666 @@ func TestDecoderArrayInArray1(t *testing.T) {
 		t.Errorf("Binson decoder error: %v", d.err)
 	}
 }
+
+func TestDecoderErrTruncated(t *testing.T) {
+	// {"a":1 -- END missing
+	var b = bytes.NewBuffer([]byte("\x40\x14\x01\x61\x10\x01"))
+	var d = NewDecoder(b)
+
+	assert.Equal(t, true, d.NextField())
+	assert.Equal(t, false, d.NextField())
+	assert.True(t, errors.Is(d.Err(), ErrUnexpectedEOF))
+
+	var se *SyntaxError
+	assert.True(t, errors.As(d.Err(), &se))
+	assert.Equal(t, int64(6), se.Offset)
+
+	// errors are sticky
+	assert.Equal(t, false, d.NextField())
+	assert.True(t, errors.Is(d.Err(), ErrUnexpectedEOF))
+}
+
+func TestDecoderErrBadTypeByte(t *testing.T) {
+	// {"a":<0x99>}
+	var b = bytes.NewBuffer([]byte("\x40\x14\x01\x61\x99\x41"))
+	var d = NewDecoder(b)
+
+	assert.Equal(t, false, d.Field("a"))
+
+	var se *SyntaxError
+	assert.True(t, errors.As(d.Err(), &se))
+	assert.Equal(t, int64(4), se.Offset)
+}
+
+func TestDecoderErrMissingField(t *testing.T) {
+	// {"cid":38, "z":{}}
+	var b = bytes.NewBuffer([]byte("\x40\x14\x03\x63\x69\x64\x10\x26\x14\x01\x7a\x40\x41\x41"))
+	var d = NewDecoder(b)
+
+	assert.Equal(t, false, d.Field("zz"))
+	assert.Nil(t, d.Err())
+
+	assert.Equal(t, false, d.NextField())
+	assert.True(t, errors.Is(d.Err(), ErrInvalidState))
+}
+
+func TestDecoderErrType(t *testing.T) {
+	// {"a":1}
+	var b = bytes.NewBuffer([]byte("\x40\x14\x01\x61\x10\x01\x41"))
+	var d = NewDecoder(b)
+
+	d.Field("a")
+	d.GoIntoArray()
+
+	var te *TypeError
+	assert.True(t, errors.As(d.Err(), &te))
+	assert.Equal(t, Array, te.Expected)
+	assert.Equal(t, Integer, te.Got)
+}
+
+func TestDecoderErrValueLength(t *testing.T) {
+	// {"a":<bytes of length 0x7fffffff>}
+	var b = bytes.NewBuffer([]byte("\x40\x14\x01\x61\x1a\xff\xff\xff\x7f"))
+	var d = NewDecoder(b)
+
+	assert.Equal(t, false, d.NextField())
+
+	var le *LimitError
+	assert.True(t, errors.As(d.Err(), &le))
+	assert.Equal(t, LimitValueLength, le.Kind)
+	assert.Equal(t, int64(0x7fffffff), le.Value)
+}
+
+func TestDecoderStreamOfObjects(t *testing.T) {
+	// {"a":1}{"b":2}
+	var b = bytes.NewBuffer([]byte("\x40\x14\x01\x61\x10\x01\x41\x40\x14\x01\x62\x10\x02\x41"))
+
+	var d = NewDecoder(b)
+	assert.Equal(t, true, d.Field("a"))
+	assert.Equal(t, int64(1), d.Value)
+	assert.Equal(t, false, d.NextField())
+	assert.Nil(t, d.Err())
+
+	d = NewDecoder(b)
+	assert.Equal(t, true, d.Field("b"))
+	assert.Equal(t, int64(2), d.Value)
+	assert.Equal(t, false, d.NextField())
+	assert.Nil(t, d.Err())
+}
+
+func TestDecoderBuffered(t *testing.T) {
+	// {"a":1}{"b":2}, read through a reader without ReadByte
+	var r = struct{ io.Reader }{bytes.NewReader([]byte("\x40\x14\x01\x61\x10\x01\x41\x40\x14\x01\x62\x10\x02\x41"))}
+
+	var d = NewDecoder(r)
+	assert.Equal(t, true, d.Field("a"))
+	assert.Equal(t, false, d.NextField())
+
+	d = NewDecoder(io.MultiReader(d.Buffered(), r))
+	assert.Equal(t, true, d.Field("b"))
+	assert.Equal(t, int64(2), d.Value)
+	assert.Nil(t, d.Err())
+}
+
+func TestBytesDecoderArrayInArray(t *testing.T) {
+	// {"a":1,"b":[10,[100,101],20],"c":3}
+	var d = NewBytesDecoder([]byte(
+		"\x40\x14\x01\x61\x10\x01\x14\x01\x62\x42\x10\x0a\x42" +
+			"\x10\x64\x10\x65\x43\x10\x14\x43\x14\x01\x63\x10\x03\x41",
+	))
+
+	d.Field("b")
+	d.GoIntoArray()
+	assert.Equal(t, true, d.NextArrayValue())
+	assert.Equal(t, int64(10), d.Value)
+	assert.Equal(t, true, d.NextArrayValue())
+	assert.Equal(t, Array, d.ValueType)
+	assert.Equal(t, true, d.NextArrayValue())
+	assert.Equal(t, int64(20), d.Value)
+	d.GoUpToObject()
+
+	assert.Equal(t, true, d.Field("c"))
+	assert.Equal(t, int64(3), d.Value)
+	assert.Equal(t, false, d.NextField())
+	assert.Nil(t, d.Err())
+}
+
+func TestBytesDecoderZeroCopy(t *testing.T) {
+	// {"b":0x008100ff00,"s":"abc"}
+	var in = []byte("\x40\x14\x01\x62\x18\x05\x00\x81\x00\xff\x00\x14\x01\x73\x14\x03\x61\x62\x63\x41")
+	var d = NewBytesDecoder(in)
+
+	assert.Equal(t, true, d.Field("b"))
+	assert.Equal(t, Bytes, d.ValueType)
+	var val = d.Value.([]byte)
+	assert.Equal(t, []byte("\x00\x81\x00\xff\x00"), val)
+	assert.Equal(t, &in[6], &val[0])
+	assert.Equal(t, 5, cap(val))
+
+	assert.Equal(t, true, d.Field("s"))
+	assert.Equal(t, "abc", d.Value)
+	assert.Nil(t, d.Err())
+}
+
+func TestBytesDecoderTruncated(t *testing.T) {
+	// {"s":"abc -- string truncated
+	var d = NewBytesDecoder([]byte("\x40\x14\x01\x73\x14\x03\x61"))
+
+	assert.Equal(t, false, d.NextField())
+	assert.True(t, errors.Is(d.Err(), ErrUnexpectedEOF))
+}
+
+func limitKindOf(err error) LimitKind {
+	var le *LimitError
+	if !errors.As(err, &le) {
+		return -1
+	}
+	return le.Kind
+}
+
+func TestDecoderLimits(t *testing.T) {
+	// {"a":[[[1]]],"b":[1,2,3],"c":"hello"}
+	var in = []byte(
+		"\x40\x14\x01\x61\x42\x42\x42\x10\x01\x43\x43\x43" +
+			"\x14\x01\x62\x42\x10\x01\x10\x02\x10\x03\x43" +
+			"\x14\x01\x63\x14\x05\x68\x65\x6c\x6c\x6f\x41",
+	)
+
+	var table = []struct {
+		opts DecoderOptions
+		kind LimitKind
+	}{
+		{DecoderOptions{MaxDepth: 3}, LimitDepth},
+		{DecoderOptions{MaxFields: 2}, LimitFields},
+		{DecoderOptions{MaxArrayLength: 2}, LimitArrayLength},
+		{DecoderOptions{MaxValueLength: 4}, LimitValueLength},
+		{DecoderOptions{MaxInputSize: int64(len(in) - 1)}, LimitInputSize},
+	}
+
+	for _, record := range table {
+		var d = NewBytesDecoderWithOptions(in, record.opts)
+		assert.Equal(t, false, d.Field("z"))
+		assert.Equal(t, record.kind, limitKindOf(d.Err()), "%+v", record.opts)
+
+		d = NewDecoderWithOptions(bytes.NewReader(in), record.opts)
+		assert.Equal(t, false, d.Field("z"))
+		assert.Equal(t, record.kind, limitKindOf(d.Err()), "%+v", record.opts)
+	}
+
+	var d = NewBytesDecoderWithOptions(in, DecoderOptions{MaxDepth: 4, MaxFields: 3, MaxArrayLength: 3, MaxValueLength: 5, MaxInputSize: int64(len(in))})
+	assert.Equal(t, false, d.Field("z"))
+	assert.Nil(t, d.Err())
+}
+
+func TestDecoderSkipDeepNesting(t *testing.T) {
+	// {"a":[[[...]]],"b":1}, skipping must not recurse
+	const depth = 100000
+	var in = []byte("\x40\x14\x01\x61")
+	in = append(in, bytes.Repeat([]byte{0x42}, depth)...)
+	in = append(in, bytes.Repeat([]byte{0x43}, depth)...)
+	in = append(in, []byte("\x14\x01\x62\x10\x01\x41")...)
+
+	var d = NewBytesDecoder(in)
+	assert.Equal(t, true, d.Field("b"))
+	assert.Equal(t, int64(1), d.Value)
+
+	d = NewBytesDecoderWithOptions(in, DecoderOptions{MaxDepth: 64})
+	assert.Equal(t, false, d.Field("b"))
+	assert.Equal(t, LimitDepth, limitKindOf(d.Err()))
+}
+
+func TestDecoderStrict(t *testing.T) {
+	var table = []struct {
+		name string
+		raw  []byte
+	}{
+		{"unsorted", []byte("\x40\x14\x01\x62\x10\x01\x14\x01\x61\x10\x02\x41")},                    // {"b":1,"a":2}
+		{"duplicate", []byte("\x40\x14\x01\x61\x10\x01\x14\x01\x61\x10\x02\x41")},                   // {"a":1,"a":2}
+		{"nested unsorted", []byte("\x40\x14\x01\x61\x40\x14\x01\x63\x45\x14\x01\x62\x45\x41\x41")}, // {"a":{"c":false,"b":false}}
+		{"integer", []byte("\x40\x14\x01\x61\x11\x01\x00\x41")},                                     // {"a":1} with int16
+		{"length", []byte("\x40\x15\x01\x00\x61\x10\x01\x41")},                                      // {"a":1} with 2-byte name length
+		{"utf-8 name", []byte("\x40\x14\x01\xff\x10\x01\x41")},                                      // {"\xff":1}
+		{"utf-8 value", []byte("\x40\x14\x01\x61\x14\x02\xc3\x28\x41")},                             // {"a":"\xc3\x28"}
+		{"trailing", []byte("\x40\x14\x01\x61\x10\x01\x41\x00")},                                    // {"a":1} + 0x00
+	}
+
+	for _, record := range table {
+		var d = NewBytesDecoder(record.raw)
+		for d.NextField() {
+		}
+		assert.Nil(t, d.Err(), record.name)
+
+		var se *SyntaxError
+		d = NewBytesDecoderWithOptions(record.raw, DecoderOptions{Strict: true})
+		for d.NextField() {
+		}
+		assert.True(t, errors.As(d.Err(), &se), record.name)
+
+		d = NewDecoderWithOptions(bytes.NewReader(record.raw), DecoderOptions{Strict: true})
+		for d.NextField() {
+		}
+		assert.True(t, errors.As(d.Err(), &se), record.name)
+	}
+}
+
+func TestDecoderStrictValid(t *testing.T) {
+	// {"":0,"a":{"a":1,"b":[128]},"ab":"größer"}
+	var raw = []byte(
+		"\x40\x14\x00\x10\x00\x14\x01\x61\x40\x14\x01\x61\x10\x01\x14\x01\x62\x42\x11\x80\x00\x43\x41" +
+			"\x14\x02\x61\x62\x14\x08\x67\x72\xc3\xb6\xc3\x9f\x65\x72\x41",
+	)
+
+	var d = NewDecoderWithOptions(bytes.NewReader(raw), DecoderOptions{Strict: true})
+	for d.NextField() {
+	}
+	assert.Nil(t, d.Err())
+}
+
+func TestDecoderSkip(t *testing.T) {
+	// {"a":{"b":[1,2]},"c":"x"}{"d":1}
+	var raw = []byte("\x40\x14\x01\x61\x40\x14\x01\x62\x42\x10\x01\x10\x02\x43\x41\x14\x01\x63\x14\x01\x78\x41\x40\x14\x01\x64\x10\x01\x41")
+
+	for _, d := range []*Decoder{NewBytesDecoder(raw), NewDecoder(bytes.NewReader(raw))} {
+		assert.Equal(t, true, d.NextField())
+		assert.Equal(t, "a", d.Name)
+		d.Skip()
+		assert.Equal(t, "a", d.Name)
+		assert.Equal(t, true, d.NextField())
+		assert.Equal(t, "c", d.Name)
+		assert.Equal(t, "x", d.Value)
+		d.Skip() // no-op for a value already read
+		assert.Equal(t, false, d.NextField())
+		assert.Nil(t, d.Err())
+	}
+
+	// skip whole objects of a stream
+	var r = bytes.NewReader(raw)
+	var d = NewDecoder(r)
+	d.Skip()
+	assert.Nil(t, d.Err())
+	d = NewDecoder(r)
+	assert.Equal(t, true, d.Field("d"))
+	assert.Equal(t, int64(1), d.Value)
+}
+
+func TestDecoderFieldSkipAllocs(t *testing.T) {
+	// {"a":<bytes>,"b":<bytes>,...,"z":1}
+	var big = bytes.Repeat([]byte{0xaa}, 1000)
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin()
+	for c := 'a'; c < 'z'; c++ {
+		e.Name(string(c))
+		e.Bytes(big)
+	}
+	e.Name("z")
+	e.Integer(1)
+	e.End()
+	e.Flush()
+	var raw = b.Bytes()
+
+	var oneField = []byte("\x40\x14\x01\x7a\x10\x01\x41")
+	var base = testing.AllocsPerRun(10, func() {
+		NewBytesDecoder(oneField).Field("z")
+	})
+	var allocs = testing.AllocsPerRun(10, func() {
+		NewBytesDecoder(raw).Field("z")
+	})
+	assert.Equal(t, base, allocs)
+
+	var rd = bytes.NewReader(oneField)
+	base = testing.AllocsPerRun(10, func() {
+		rd.Reset(oneField)
+		NewDecoder(rd).Field("z")
+	})
+	allocs = testing.AllocsPerRun(10, func() {
+		rd.Reset(raw)
+		NewDecoder(rd).Field("z")
+	})
+	assert.Equal(t, base+1, allocs) // the discard buffer
+}
+
+func TestDecoderTypedAccessors(t *testing.T) {
+	// {"a":true,"b":0x0102,"c":1.5,"d":-7,"e":"hi","f":{}}
+	var raw = []byte(
+		"\x40\x14\x01\x61\x44\x14\x01\x62\x18\x02\x01\x02\x14\x01\x63\x46\x00\x00\x00\x00\x00\x00\xf8\x3f" +
+			"\x14\x01\x64\x10\xf9\x14\x01\x65\x14\x02\x68\x69\x14\x01\x66\x40\x41\x41",
+	)
+
+	for _, d := range []*Decoder{
+		NewBytesDecoderWithOptions(raw, DecoderOptions{NoValue: true}),
+		NewDecoder(bytes.NewReader(raw)),
+	} {
+		d.Field("a")
+		vb, err := d.Bool()
+		assert.Nil(t, err)
+		assert.Equal(t, true, vb)
+
+		d.Field("b")
+		vbs, err := d.BytesValue()
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("\x01\x02"), vbs)
+
+		d.Field("c")
+		vf, err := d.Float()
+		assert.Nil(t, err)
+		assert.Equal(t, 1.5, vf)
+
+		d.Field("d")
+		vi, err := d.Int()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(-7), vi)
+
+		var te *TypeError
+		_, err = d.Str()
+		assert.True(t, errors.As(err, &te))
+		assert.Equal(t, String, te.Expected)
+		assert.Equal(t, Integer, te.Got)
+		assert.Nil(t, d.Err()) // a type mismatch is not sticky
+
+		d.Field("e")
+		vs, err := d.Str()
+		assert.Nil(t, err)
+		assert.Equal(t, "hi", vs)
+
+		d.Field("f")
+		_, err = d.Int()
+		assert.True(t, errors.As(err, &te))
+		assert.Equal(t, Object, te.Got)
+
+		assert.Equal(t, false, d.NextField())
+		_, err = d.Str()
+		assert.True(t, errors.Is(err, ErrInvalidState))
+	}
+}
+
+func TestDecoderNoValueAllocs(t *testing.T) {
+	// {"a":1000,"b":2.5}
+	var raw = []byte("\x40\x14\x01\x61\x11\xe8\x03\x14\x01\x62\x46\x00\x00\x00\x00\x00\x00\x04\x40\x41")
+	var d = NewBytesDecoderWithOptions(raw, DecoderOptions{NoValue: true})
+	d.NextField()
+	assert.Nil(t, d.Value)
+
+	var allocs = testing.AllocsPerRun(10, func() {
+		d = NewBytesDecoderWithOptions(raw, DecoderOptions{NoValue: true})
+		d.NextField()
+		d.NextField()
+	})
+	var boxed = testing.AllocsPerRun(10, func() {
+		d = NewBytesDecoder(raw)
+		d.NextField()
+		d.NextField()
+	})
+	assert.Equal(t, allocs+2, boxed)
+}
+
+func TestDecoderFieldStopsAtSortPosition(t *testing.T) {
+	// {"a":1,"c":{"x":1},"e":5}
+	var raw = []byte("\x40\x14\x01\x61\x10\x01\x14\x01\x63\x40\x14\x01\x78\x10\x01\x41\x14\x01\x65\x10\x05\x41")
+
+	for _, d := range []*Decoder{NewBytesDecoder(raw), NewDecoder(bytes.NewReader(raw))} {
+		assert.Equal(t, false, d.Field("b"))
+		assert.Equal(t, true, d.Field("c"))
+		assert.Equal(t, Object, d.ValueType)
+		assert.Equal(t, false, d.Field("d"))
+		assert.Equal(t, true, d.NextField())
+		assert.Equal(t, "e", d.Name)
+		assert.Equal(t, int64(5), d.Value)
+		assert.Nil(t, d.Err())
+	}
+
+	// a read-ahead field is still seen by Token
+	var d = NewBytesDecoder(raw)
+	assert.Equal(t, false, d.Field("0"))
+	tok, err := d.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, Token{Kind: TokenName, Name: "a"}, tok)
+}
+
+func TestDecoderFields(t *testing.T) {
+	// {"a":1,"c":{"x":1},"e":5}
+	var raw = []byte("\x40\x14\x01\x61\x10\x01\x14\x01\x63\x40\x14\x01\x78\x10\x01\x41\x14\x01\x65\x10\x05\x41")
+
+	var d = NewBytesDecoder(raw)
+	var got = map[string]interface{}{}
+	var all = d.Fields(func(name string) {
+		if d.ValueType == Object {
+			d.GoIntoObject()
+			d.Field("x")
+			got[name+".x"] = d.Value
+			d.GoUpToObject()
+			return
+		}
+		got[name] = d.Value
+	}, "e", "c", "a", "e")
+
+	assert.Equal(t, true, all)
+	assert.Equal(t, map[string]interface{}{"a": int64(1), "c.x": int64(1), "e": int64(5)}, got)
+	assert.Equal(t, false, d.NextField())
+	assert.Nil(t, d.Err())
+
+	d = NewBytesDecoder(raw)
+	got = map[string]interface{}{}
+	all = d.Fields(func(name string) { got[name] = d.Value }, "z", "e", "b")
+	assert.Equal(t, false, all)
+	assert.Equal(t, map[string]interface{}{"e": int64(5)}, got)
+	assert.Nil(t, d.Err())
+}
+
+func TestDecoderPath(t *testing.T) {
+	// {"a":{"b":[1,2,{"c":"x"}]},"d":true}
+	var raw = []byte("\x40\x14\x01\x61\x40\x14\x01\x62\x42\x10\x01\x10\x02\x40\x14\x01\x63\x14\x01\x78\x41\x43\x41\x14\x01\x64\x44\x41")
+
+	var d = NewBytesDecoder(raw)
+	assert.Equal(t, 0, d.Depth())
+	assert.Equal(t, "", d.Path())
+	d.Field("a")
+	assert.Equal(t, 1, d.Depth())
+	assert.Equal(t, "a", d.Path())
+	d.GoIntoObject()
+	d.Field("b")
+	d.GoIntoArray()
+	assert.Equal(t, 3, d.Depth())
+	assert.Equal(t, "a.b", d.Path())
+	d.NextArrayValue()
+	assert.Equal(t, "a.b[0]", d.Path())
+	d.NextArrayValue()
+	d.NextArrayValue()
+	d.GoIntoObject()
+	d.Field("c")
+	assert.Equal(t, 4, d.Depth())
+	assert.Equal(t, "a.b[2].c", d.Path())
+	assert.Equal(t, int64(20), d.Offset())
+
+	// the parent of the inner object is an array
+	d.GoUpToObject()
+	assert.Equal(t, 3, d.Depth())
+	assert.Equal(t, false, d.NextArrayValue())
+	d.GoUpToObject()
+	assert.Equal(t, 2, d.Depth())
+	d.GoUpToObject()
+	assert.Equal(t, true, d.NextField())
+	assert.Equal(t, "d", d.Path())
+	assert.Nil(t, d.Err())
+
+	d = NewBytesDecoder(raw[:18])
+	assert.Equal(t, false, d.Field("d"))
+	var se *SyntaxError
+	assert.True(t, errors.As(d.Err(), &se))
+	assert.Equal(t, "a.b[2].c", se.Path)
+	assert.Equal(t, int64(18), se.Offset)
+	assert.Contains(t, se.Error(), "path a.b[2].c")
+
+	d = NewBytesDecoder(raw)
+	d.Field("d")
+	_, err := d.Int()
+	var te *TypeError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, "d", te.Path)
+}
+
+func TestDecoderEncoderReset(t *testing.T) {
+	var out bytes.Buffer
+	var e = NewEncoderSize(&out, 64)
+	var in = bytes.NewReader(nil)
+	var r = &struct{ io.Reader }{in}
+	var d = NewDecoderWithOptions(r, DecoderOptions{BufferSize: 64, NoValue: true})
+
+	var roundTrip = func(i int64) int64 {
+		out.Reset()
+		e.Reset(&out)
+		e.Begin()
+		e.Name("a")
+		e.Integer(i)
+		e.Name("b")
+		e.Double(1.5)
+		e.End()
+		e.Flush()
+
+		in.Reset(out.Bytes())
+		d.Reset(r)
+		d.Field("a")
+		got, _ := d.Int()
+		d.Field("b")
+		return got
+	}
+	assert.Equal(t, int64(1), roundTrip(1))
+	assert.Equal(t, int64(-300), roundTrip(-300))
+	assert.Nil(t, d.Err())
+
+	// the encoder output is discarded and the state is cleared
+	e.Begin()
+	e.Reset(&out)
+	d.Field("c")
+	d.Reset(r)
+	assert.Equal(t, int64(70000), roundTrip(70000))
+	assert.Nil(t, d.Err())
+
+	var allocs = testing.AllocsPerRun(10, func() { roundTrip(1 << 40) })
+	assert.Equal(t, 0.0, allocs)
+
+	// a reset decoder may switch between stream and in-memory input
+	var raw = append([]byte(nil), out.Bytes()...)
+	d.ResetBytes(raw)
+	assert.Equal(t, true, d.NextField())
+	assert.Equal(t, "a", d.Name)
+	d.Reset(bytes.NewReader(raw))
+	assert.Equal(t, true, d.NextField())
+	assert.Equal(t, "a", d.Name)
+	assert.Equal(t, []byte(out.Bytes()), raw)
+}
+
+func TestEncoderNesting(t *testing.T) {
+	var invalid = []struct {
+		name  string
+		write func(e *Encoder)
+	}{
+		{"name in array", func(e *Encoder) { e.Begin(); e.Name("x"); e.BeginArray(); e.Name("a") }},
+		{"name at top level", func(e *Encoder) { e.Name("a") }},
+		{"value without name", func(e *Encoder) { e.Begin(); e.Integer(1) }},
+		{"two names", func(e *Encoder) { e.Begin(); e.Name("a"); e.Name("b") }},
+		{"name without value", func(e *Encoder) { e.Begin(); e.Name("a"); e.End() }},
+		{"unbalanced End", func(e *Encoder) { e.Begin(); e.End(); e.End() }},
+		{"EndArray closing object", func(e *Encoder) { e.Begin(); e.EndArray() }},
+		{"End closing array", func(e *Encoder) { e.Begin(); e.Name("x"); e.BeginArray(); e.End() }},
+		{"raw value without name", func(e *Encoder) { e.Begin(); e.RawValue(RawValue("\x44")) }},
+		{"value at top level", func(e *Encoder) { e.String("a") }},
+		{"value after top level", func(e *Encoder) { e.Begin(); e.End(); e.Bool(true) }},
+		{"raw value at top level", func(e *Encoder) { e.RawValue(RawValue("\x44")) }},
+		{"array at top level", func(e *Encoder) { e.BeginArray(); e.EndArray() }},
+		{"empty raw value", func(e *Encoder) { e.Begin(); e.Name("a"); e.RawValue(nil); e.End() }},
+	}
+	for _, record := range invalid {
+		var b bytes.Buffer
+		var e = NewEncoder(&b)
+		record.write(e)
+		e.Integer(1) // skipped after the error
+		e.Flush()
+		assert.True(t, errors.Is(e.Err(), ErrInvalidNesting), record.name)
+		assert.True(t, errors.Is(e.Done(), ErrInvalidNesting), record.name)
+		assert.False(t, bytes.HasSuffix(b.Bytes(), []byte("\x10\x01")), record.name)
+	}
+
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin()
+	e.Name("a")
+	e.BeginArray()
+	e.Begin()
+	e.End()
+	e.EndArray()
+	assert.True(t, errors.Is(e.Done(), ErrInvalidNesting))
+	e.Reset(&b)
+	assert.True(t, errors.Is(e.Done(), ErrInvalidNesting)) // no object
+	e.Reset(&b)
+	e.Begin() // several objects may follow each other
+	e.End()
+	e.RawValue(RawValue("\x40\x41"))
+	assert.Nil(t, e.Done())
+
+	// the checks can be turned off
+	e = NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
+	e.BeginArray()
+	e.Name("a")
+	e.End()
+	assert.Nil(t, e.Done())
+	e.Flush()
+}
+
+// failingWriter accepts limit bytes, then fails every write with a new error
+type failingWriter struct {
+	limit  int
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, fmt.Errorf("write %d failed", w.writes)
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	var writes = []struct {
+		name  string
+		write func(e *Encoder)
+	}{
+		{"bool", func(e *Encoder) { e.Bool(true) }},
+		{"integer", func(e *Encoder) { e.Integer(1 << 40) }},
+		{"double", func(e *Encoder) { e.Double(1) }},
+		{"string", func(e *Encoder) { e.String("abcdefgh") }},
+		{"bytes", func(e *Encoder) { e.Bytes([]byte("abcdefgh")) }},
+		{"raw value", func(e *Encoder) { e.RawValue(RawValue("\x14\x08abcdefgh")) }},
+		{"sorted", func(e *Encoder) { e.BeginSorted(); e.Name("a"); e.String("abcdefgh"); e.End() }},
+	}
+	for _, record := range writes {
+		var w = &failingWriter{limit: 4}
+		var e = NewEncoderSize(w, 16)
+		e.Begin()
+		e.Name("a")
+		e.BeginArray()
+		for i := 0; i < 20; i++ {
+			record.write(e)
+		}
+		assert.EqualError(t, e.Err(), "write 1 failed", record.name)
+		e.Integer(1)
+		assert.EqualError(t, e.Flush(), "write 1 failed", record.name)
+		assert.EqualError(t, e.Err(), "write 1 failed", record.name)
+		assert.Equal(t, 1, w.writes, record.name)
+	}
+
+	// an error left in the buffer is returned by Flush
+	var w = &failingWriter{limit: 0}
+	var e = NewEncoder(w)
+	e.Begin()
+	assert.Nil(t, e.Err())
+	assert.EqualError(t, e.Flush(), "write 1 failed")
+	assert.EqualError(t, e.Flush(), "write 1 failed")
+
+	e.Reset(w)
+	assert.Nil(t, e.Err())
+}
@@ -0,0 +1,113 @@
+package binson
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnexpectedEOF means that the input ended in the middle of a Binson
+// object. The Decoder reports it wrapped in a *SyntaxError, use errors.Is
+// to test for it.
+var ErrUnexpectedEOF = errors.New("binson: unexpected end of input")
+
+// ErrInvalidState is returned when a Decoder method is called while the
+// decoder is not positioned where the method expects, for example when
+// NextField is called after end-of-object was already reached.
+var ErrInvalidState = errors.New("binson: invalid decoder state")
+
+// ErrInvalidNesting is recorded by an Encoder when names, values and the
+// ends of objects and arrays are written in an invalid order, for example
+// a Name inside an ARRAY or an EndArray closing an OBJECT.
+var ErrInvalidNesting = errors.New("binson: invalid encoder nesting")
+
+// ErrDuplicateName is recorded by an Encoder when an object begun with
+// BeginSorted gets two fields with the same name.
+var ErrDuplicateName = errors.New("binson: duplicate field name")
+
+// A SyntaxError describes malformed Binson input.
+type SyntaxError struct {
+	msg    string
+	err    error
+	Offset int64  // input offset of the byte where the error was detected
+	Path   string // decoder path where the error was detected, see Decoder.Path
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("binson: %s (%s)", e.msg, position(e.Path, e.Offset))
+}
+
+// Unwrap returns the underlying error, if any (e.g. ErrUnexpectedEOF).
+func (e *SyntaxError) Unwrap() error { return e.err }
+
+// LimitKind identifies a decoder limit.
+type LimitKind int
+
+// Decoder limits enumeration
+const (
+	LimitValueLength LimitKind = iota // length of a STRING/BYTES value
+	LimitDepth                        // nesting depth of OBJECT/ARRAY values
+	LimitFields                       // number of fields in an OBJECT
+	LimitArrayLength                  // number of values in an ARRAY
+	LimitInputSize                    // total number of input bytes
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LimitValueLength:
+		return "value length"
+	case LimitDepth:
+		return "depth"
+	case LimitFields:
+		return "field count"
+	case LimitArrayLength:
+		return "array length"
+	case LimitInputSize:
+		return "input size"
+	default:
+		return fmt.Sprintf("LimitKind(%d)", int(k))
+	}
+}
+
+// A LimitError is returned when the input exceeds one of the decoder limits.
+type LimitError struct {
+	Kind   LimitKind
+	Max    int64  // configured maximum
+	Value  int64  // offending value found in the input
+	Offset int64  // input offset where the limit was exceeded
+	Path   string // decoder path where the limit was exceeded
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("binson: %v limit exceeded: %d > %d (%s)", e.Kind, e.Value, e.Max, position(e.Path, e.Offset))
+}
+
+// A TypeError is returned when the current value is not of the type
+// required by the requested operation.
+type TypeError struct {
+	Expected ValueType
+	Got      ValueType
+	Offset   int64  // input offset after the current value
+	Path     string // decoder path of the current value
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("binson: expected %v, got %v (%s)", e.Expected, e.Got, position(e.Path, e.Offset))
+}
+
+func position(path string, offset int64) string {
+	if path == "" {
+		return fmt.Sprintf("offset %d", offset)
+	}
+	return fmt.Sprintf("path %s, offset %d", path, offset)
+}
+
+// An UnsupportedTypeError is returned by Marshal and Unmarshal for a Go
+// type that cannot be mapped to Binson.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "binson: unsupported type: " + e.Type.String()
+}
@@ -0,0 +1,105 @@
+package binson
+
+import (
+	"fmt"
+	"iter"
+)
+
+// FieldsSeq returns an iterator over the fields of the OBJECT the decoder
+// is positioned before, or of the top-level object at the start of input.
+// The object is entered, each field is yielded with its name and type, like
+// after NextField, and the object is left when the loop ends or breaks.
+//
+// The loop body may decode the value of a field, including entering it or
+// ranging over it with FieldsSeq/ArraySeq; whatever it leaves unread is
+// skipped. Errors stop the iteration and are available from Err.
+func (d *Decoder) FieldsSeq() iter.Seq2[string, ValueType] {
+	return func(yield func(string, ValueType) bool) {
+		depth, ok := d.enter(Object)
+		if !ok {
+			return
+		}
+		for d.restore(depth) && d.NextField() {
+			if !yield(d.Name, d.ValueType) {
+				break
+			}
+		}
+		d.leave(depth)
+	}
+}
+
+// ArraySeq returns an iterator over the values of the ARRAY the decoder is
+// positioned before. Each value is yielded with its index and type, like
+// after NextArrayValue, otherwise ArraySeq works like FieldsSeq.
+func (d *Decoder) ArraySeq() iter.Seq2[int, ValueType] {
+	return func(yield func(int, ValueType) bool) {
+		depth, ok := d.enter(Array)
+		if !ok {
+			return
+		}
+		for i := 0; d.restore(depth) && d.NextArrayValue(); i++ {
+			if !yield(i, d.ValueType) {
+				break
+			}
+		}
+		d.leave(depth)
+	}
+}
+
+/* === private methods === */
+
+// enter goes into the OBJECT/ARRAY the decoder is positioned before,
+// returns the depth of the decoder inside it.
+func (d *Decoder) enter(kind ValueType) (int, bool) {
+	if d.err != nil {
+		return 0, false
+	}
+
+	switch {
+	case d.state == stateZero && kind == Object:
+		d.parseBegin()
+	case kind == Object:
+		d.GoIntoObject()
+	default:
+		d.GoIntoArray()
+	}
+	return len(d.stack), d.err == nil
+}
+
+// restore returns the decoder to the container at depth after the body
+// of an iterator loop, skipping what the body left unread.
+func (d *Decoder) restore(depth int) bool {
+	if d.err != nil {
+		return false
+	}
+	if len(d.stack) < depth {
+		d.fail(fmt.Errorf("%w: iterated container left inside loop", ErrInvalidState))
+		return false
+	}
+
+	for len(d.stack) > depth && d.err == nil {
+		d.skipUp()
+		d.stateAfterValue()
+	}
+	switch d.state {
+	case stateEndOfObject, stateEndOfArray:
+		// a nested container was read to its end
+		d.stateAfterValue()
+	}
+	return d.err == nil
+}
+
+// leave skips the rest of the container at depth and continues after it.
+func (d *Decoder) leave(depth int) {
+	if len(d.stack) >= depth && d.restore(depth) {
+		d.skipUp()
+	}
+	if d.err != nil {
+		return
+	}
+	if len(d.stack) > 0 {
+		d.stateAfterValue()
+	} else {
+		d.state = stateEndOfObject
+	}
+}
@@ -0,0 +1,106 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// {"a":{"b":[1,2,{"c":"x"}]},"d":[[3],4],"e":true}
+var iterRaw = []byte(
+	"\x40\x14\x01\x61\x40\x14\x01\x62\x42\x10\x01\x10\x02\x40\x14\x01\x63\x14\x01\x78\x41\x43\x41" +
+		"\x14\x01\x64\x42\x42\x10\x03\x43\x10\x04\x43\x14\x01\x65\x44\x41",
+)
+
+func TestDecoderFieldsSeq(t *testing.T) {
+	for _, d := range []*Decoder{NewBytesDecoder(iterRaw), NewDecoder(bytes.NewReader(iterRaw))} {
+		var got []string
+		var walk func()
+		walk = func() {
+			switch d.ValueType {
+			case Object:
+				for name := range d.FieldsSeq() {
+					got = append(got, name)
+					walk()
+				}
+			case Array:
+				for i := range d.ArraySeq() {
+					got = append(got, d.Path())
+					assert.Equal(t, i, d.stack[len(d.stack)-1].count-1)
+					walk()
+				}
+			default:
+				got = append(got, d.Path())
+			}
+		}
+		d.ValueType = Object
+		walk()
+
+		assert.Nil(t, d.Err())
+		assert.Equal(t, []string{
+			"a", "b", "a.b[0]", "a.b[0]", "a.b[1]", "a.b[1]", "a.b[2]", "c", "a.b[2].c",
+			"d", "d[0]", "d[0][0]", "d[0][0]", "d[1]", "d[1]", "e", "e",
+		}, got)
+		assert.Equal(t, int64(len(iterRaw)), d.Offset())
+	}
+}
+
+func TestDecoderSeqBreak(t *testing.T) {
+	var d = NewBytesDecoder(iterRaw)
+	var names []string
+	for name, vt := range d.FieldsSeq() {
+		names = append(names, name)
+		if vt == Array {
+			for i := range d.ArraySeq() {
+				if i == 0 {
+					d.GoIntoArray() // left for the iterator to skip
+					break
+				}
+			}
+		}
+		if name == "a" {
+			for range d.FieldsSeq() {
+				break
+			}
+		}
+	}
+	assert.Nil(t, d.Err())
+	assert.Equal(t, []string{"a", "d", "e"}, names)
+	assert.Equal(t, int64(len(iterRaw)), d.Offset())
+
+	// breaking out of the top-level object skips the rest of it
+	d = NewDecoder(bytes.NewReader(append(iterRaw, 0x40)))
+	for range d.FieldsSeq() {
+		break
+	}
+	assert.Nil(t, d.Err())
+	assert.Equal(t, int64(len(iterRaw)), d.Offset())
+}
+
+func TestDecoderSeqErrors(t *testing.T) {
+	var d = NewBytesDecoder(iterRaw[:20])
+	var n = 0
+	for range d.FieldsSeq() {
+		n++
+	}
+	assert.Equal(t, 1, n)
+	assert.True(t, errors.Is(d.Err(), ErrUnexpectedEOF))
+
+	d = NewBytesDecoder(iterRaw)
+	d.Field("e")
+	for range d.ArraySeq() {
+		t.Fail()
+	}
+	var te *TypeError
+	assert.True(t, errors.As(d.Err(), &te))
+
+	// leaving the iterated object inside the loop
+	d = NewBytesDecoder(iterRaw)
+	d.Field("a")
+	for range d.FieldsSeq() {
+		d.GoUpToObject()
+	}
+	assert.True(t, errors.Is(d.Err(), ErrInvalidState))
+}
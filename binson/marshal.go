@@ -0,0 +1,511 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Marshaler is implemented by types that encode themselves. MarshalBinson
+// must write exactly one value to e, the field name is written by the caller.
+type Marshaler interface {
+	MarshalBinson(e *Encoder) error
+}
+
+// Unmarshaler is implemented by types that decode themselves.
+// UnmarshalBinson is called with d positioned at the value, like after
+// NextField, or at the start of input for the top-level object. Whatever
+// it leaves unread of the value is skipped.
+type Unmarshaler interface {
+	UnmarshalBinson(d *Decoder) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	valueType       = reflect.TypeOf((*Value)(nil)).Elem()
+)
+
+// Marshal returns the Binson encoding of v, which must be a struct or a map
+// with string keys, or a pointer to one.
+//
+// Booleans, integers, floating point numbers and strings are encoded as
+// BOOLEAN, INTEGER, DOUBLE and STRING. Byte slices and arrays are encoded
+// as BYTES, other slices and arrays as ARRAY. Structs and maps with string
+// keys are encoded as OBJECT, with the fields in Binson sort order.
+// Pointers and interfaces are encoded as the value they point to, a field
+// holding a nil pointer or interface is left out since Binson has no null.
+//
+// Exported struct fields are encoded using the field name, unless the
+// field has a tag like `binson:"name,omitempty"`. The "omitempty" option
+// leaves the field out if it has an empty value, like in encoding/json.
+// A field with the tag `binson:"-"` is ignored.
+//
+// Values implementing Marshaler, including ObjectValue, encode themselves.
+func Marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	if err := marshal(e, v); err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Unmarshal decodes the Binson object in data into the value pointed to
+// by v, using the type mapping of Marshal. Fields without a matching struct
+// field are ignored. Slices, maps and pointers are allocated as needed, an
+// interface{} gets a map[string]any, []any, int64, float64, string, []byte
+// or bool value, see DecodeAny, and a Value gets the in-memory form.
+// Values implementing Unmarshaler, including ObjectValue, decode
+// themselves. A *TypeError is returned if a value does not have the type
+// required by the Go value.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("binson: Unmarshal requires a non-nil pointer, got %T", v)
+	}
+
+	var d = NewBytesDecoderWithOptions(data, DecoderOptions{NoValue: true})
+	if err := decodeReflect(d, rv); err != nil {
+		return err
+	}
+	// skip what an Unmarshaler left unread
+	switch {
+	case d.state == stateZero:
+		d.Skip()
+	case len(d.stack) > 0:
+		d.leave(1)
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if d.off != int64(len(data)) {
+		return &SyntaxError{msg: "trailing data after top-level object", Offset: d.off}
+	}
+	return nil
+}
+
+// ToObject converts v, a value accepted by Marshal, to the in-memory
+// ObjectValue of its encoding. Values implementing Marshaler are
+// converted to what they encode, their fields may be in any order.
+func ToObject(v any) (ObjectValue, error) {
+	raw, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// not strict, an ObjectValue does not keep the order of the fields
+	var obj ObjectValue
+	err = obj.UnmarshalBinson(NewBytesDecoderWithOptions(raw, DecoderOptions{NoValue: true}))
+	return obj, err
+}
+
+// FromObject stores the contents of o in the value pointed to by v,
+// like Unmarshal would for the encoding of o. Values implementing
+// Unmarshaler decode themselves.
+func FromObject(o ObjectValue, v any) error {
+	raw, err := o.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return Unmarshal(raw, v)
+}
+
+/* === private functions === */
+
+// marshal writes v to e like Marshal, with all containers closed.
+func marshal(e *Encoder, v any) error {
+	if m, ok := v.(Marshaler); ok {
+		if err := m.MarshalBinson(e); err != nil {
+			return err
+		}
+	} else {
+		rv, err := topLevel(reflect.ValueOf(v))
+		if err != nil {
+			return err
+		}
+		if err = encodeReflect(e, rv); err != nil {
+			return err
+		}
+	}
+	return e.Done()
+}
+
+// topLevel returns the struct or map that v holds or points to.
+func topLevel(v reflect.Value) (reflect.Value, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, errors.New("binson: cannot encode nil as object")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct && v.Kind() != reflect.Map {
+		if !v.IsValid() {
+			return v, errors.New("binson: cannot encode nil as object")
+		}
+		return v, fmt.Errorf("binson: cannot encode %v as object", v.Type())
+	}
+	return v, nil
+}
+
+func encodeReflect(e *Encoder, v reflect.Value) error {
+	if m, ok := marshaler(v); ok {
+		if err := m.MarshalBinson(e); err != nil {
+			return err
+		}
+		return e.Err()
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.Bool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.Integer(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return fmt.Errorf("binson: %v value %d overflows INTEGER", v.Type(), u)
+		}
+		e.Integer(int64(u))
+	case reflect.Float32, reflect.Float64:
+		e.Double(v.Float())
+	case reflect.String:
+		e.String(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.Bytes(byteSlice(v))
+			return nil
+		}
+		e.BeginArray()
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeReflect(e, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.EndArray()
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return &UnsupportedTypeError{v.Type()}
+		}
+		keys := make([]string, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			keys = append(keys, it.Key().String())
+		}
+		sort.Strings(keys)
+
+		e.Begin()
+		for _, key := range keys {
+			item := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+			if isNil(item) {
+				continue
+			}
+			e.Name(key)
+			if err := encodeReflect(e, item); err != nil {
+				return err
+			}
+		}
+		e.End()
+	case reflect.Struct:
+		fields, err := structFields(v.Type())
+		if err != nil {
+			return err
+		}
+		e.Begin()
+		for i := range fields {
+			f := &fields[i]
+			item := v.Field(f.index)
+			if isNil(item) || f.omitEmpty && isEmpty(item) {
+				continue
+			}
+			e.Name(f.name)
+			if err := encodeReflect(e, item); err != nil {
+				return err
+			}
+		}
+		e.End()
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return fmt.Errorf("binson: cannot encode nil %v", v.Type())
+		}
+		return encodeReflect(e, v.Elem())
+	default:
+		return &UnsupportedTypeError{v.Type()}
+	}
+	return nil
+}
+
+// marshaler returns v, or a pointer to v, as a Marshaler if it is one.
+func marshaler(v reflect.Value) (Marshaler, bool) {
+	if isNil(v) {
+		return nil, false
+	}
+	if v.Type().Implements(marshalerType) {
+		return v.Interface().(Marshaler), true
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(marshalerType) {
+		return v.Addr().Interface().(Marshaler), true
+	}
+	return nil, false
+}
+
+// byteSlice returns the contents of a byte slice or array.
+func byteSlice(v reflect.Value) []byte {
+	if v.Kind() == reflect.Slice {
+		return v.Bytes()
+	}
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return b
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isEmpty reports whether v is false, 0, an empty string, slice or map,
+// which "omitempty" leaves out.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return v.IsZero()
+	}
+	return false
+}
+
+// decodeReflect decodes the current value of d into v,
+// at the start of input the top-level object.
+func decodeReflect(d *Decoder, v reflect.Value) error {
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(unmarshalerType) {
+		if err := v.Addr().Interface().(Unmarshaler).UnmarshalBinson(d); err != nil {
+			return err
+		}
+		return d.err
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeReflect(d, v.Elem())
+	case reflect.Interface:
+		if v.Type() == valueType {
+			item, err := decodeValue(d)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(item))
+			return nil
+		}
+		if v.NumMethod() != 0 {
+			return &UnsupportedTypeError{v.Type()}
+		}
+		item, err := DecodeAny(d)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(item))
+		return nil
+	}
+
+	t := v.Type()
+	switch v.Kind() {
+	case reflect.Bool:
+		if err := d.expect(Boolean); err != nil {
+			return err
+		}
+		v.SetBool(d.boolVal)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if err := d.expect(Integer); err != nil {
+			return err
+		}
+		if v.OverflowInt(d.intVal) {
+			return d.overflow(t)
+		}
+		v.SetInt(d.intVal)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if err := d.expect(Integer); err != nil {
+			return err
+		}
+		if d.intVal < 0 || v.OverflowUint(uint64(d.intVal)) {
+			return d.overflow(t)
+		}
+		v.SetUint(uint64(d.intVal))
+	case reflect.Float32, reflect.Float64:
+		if err := d.expect(Double); err != nil {
+			return err
+		}
+		v.SetFloat(d.floatVal)
+	case reflect.String:
+		if err := d.expect(String); err != nil {
+			return err
+		}
+		v.SetString(d.strVal)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if err := d.expect(Bytes); err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, d.bytesVal...))
+			return nil
+		}
+		if err := d.expect(Array); err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(t, 0, 0)
+		for i := range d.ArraySeq() {
+			s = reflect.Append(s, reflect.Zero(t.Elem()))
+			if err := decodeReflect(d, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if err := d.expect(Bytes); err != nil {
+				return err
+			}
+			v.SetZero()
+			reflect.Copy(v, reflect.ValueOf(d.bytesVal))
+			return nil
+		}
+		if err := d.expect(Array); err != nil {
+			return err
+		}
+		v.SetZero()
+		for i := range d.ArraySeq() {
+			if i >= v.Len() {
+				break
+			}
+			if err := decodeReflect(d, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return &UnsupportedTypeError{t}
+		}
+		if err := d.expect(Object); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for name := range d.FieldsSeq() {
+			item := reflect.New(t.Elem()).Elem()
+			if err := decodeReflect(d, item); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), item)
+		}
+	case reflect.Struct:
+		fields, err := structFields(t)
+		if err != nil {
+			return err
+		}
+		if err := d.expect(Object); err != nil {
+			return err
+		}
+		for name := range d.FieldsSeq() {
+			i := sort.Search(len(fields), func(i int) bool { return fields[i].name >= name })
+			if i == len(fields) || fields[i].name != name {
+				continue
+			}
+			if err := decodeReflect(d, v.Field(fields[i].index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return &UnsupportedTypeError{t}
+	}
+	return d.err
+}
+
+// expect returns a *TypeError if the current value, at the start of input
+// the top-level object, does not have type t.
+func (d *Decoder) expect(t ValueType) error {
+	if d.err != nil {
+		return d.err
+	}
+	got := d.ValueType
+	if d.state == stateZero {
+		got = Object
+	}
+	if got != t {
+		return &TypeError{Expected: t, Got: got, Offset: d.off, Path: d.Path()}
+	}
+	return nil
+}
+
+func (d *Decoder) overflow(t reflect.Type) error {
+	return fmt.Errorf("binson: value %d overflows %v (%s)", d.intVal, t, position(d.Path(), d.off))
+}
+
+// fieldInfo describes how a struct field is encoded
+type fieldInfo struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+type structInfo struct {
+	fields []fieldInfo // sorted by name
+	err    error
+}
+
+var structCache sync.Map // reflect.Type -> *structInfo
+
+// structFields returns the encoded fields of struct type t, sorted by name.
+func structFields(t reflect.Type) ([]fieldInfo, error) {
+	if info, ok := structCache.Load(t); ok {
+		return info.(*structInfo).fields, info.(*structInfo).err
+	}
+
+	var info structInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("binson")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		info.fields = append(info.fields, fieldInfo{
+			name:      name,
+			index:     i,
+			omitEmpty: slices.Contains(strings.Split(opts, ","), "omitempty"),
+		})
+	}
+	sort.Slice(info.fields, func(i, j int) bool { return info.fields[i].name < info.fields[j].name })
+	for i := 1; i < len(info.fields); i++ {
+		if info.fields[i].name == info.fields[i-1].name {
+			info.err = fmt.Errorf("binson: duplicate field name %q in %v", info.fields[i].name, t)
+			break
+		}
+	}
+
+	actual, _ := structCache.LoadOrStore(t, &info)
+	return actual.(*structInfo).fields, actual.(*structInfo).err
+}
@@ -0,0 +1,239 @@
+package binson
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type marshalInner struct {
+	ID   uint16 `binson:"id"`
+	Tags []string
+}
+
+type marshalMsg struct {
+	Zeta    int64           `binson:"z"`
+	Alpha   string          `binson:"a,omitempty"`
+	Flag    bool            `binson:"flag"`
+	Ratio   float32         `binson:"r"`
+	Data    []byte          `binson:"d"`
+	Key     [4]byte         `binson:"k"`
+	Inner   *marshalInner   `binson:"inner"`
+	List    []marshalInner  `binson:"list"`
+	Attrs   map[string]int8 `binson:"attrs"`
+	Any     interface{}     `binson:"any"`
+	Skipped string          `binson:"-"`
+	hidden  int
+	Empty   map[string]string `binson:"e,omitempty"`
+	Count   int               `binson:"cnt,omitempty,other"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	var msg = marshalMsg{
+		Zeta:    -5,
+		Flag:    true,
+		Ratio:   0.5,
+		Data:    []byte{1, 2},
+		Key:     [4]byte{9, 8, 7, 6},
+		Inner:   &marshalInner{ID: 300, Tags: []string{"x", "y"}},
+		List:    []marshalInner{{ID: 1}, {ID: 2}},
+		Attrs:   map[string]int8{"b": 2, "a": 1},
+		Any:     map[string]any{"n": int64(1), "l": []any{"s", true}},
+		Skipped: "not encoded",
+		hidden:  1,
+	}
+
+	raw, err := Marshal(&msg)
+	assert.Nil(t, err)
+
+	// the encoding is valid Binson with the fields in sort order
+	var d = NewBytesDecoderWithOptions(raw, DecoderOptions{Strict: true})
+	var names []string
+	for name := range d.FieldsSeq() {
+		names = append(names, name)
+	}
+	assert.Nil(t, d.Err())
+	assert.Equal(t, []string{"any", "attrs", "d", "flag", "inner", "k", "list", "r", "z"}, names)
+
+	var got marshalMsg
+	assert.Nil(t, Unmarshal(raw, &got))
+	msg.Skipped, msg.hidden = "", 0
+	msg.List[0].Tags, msg.List[1].Tags = []string{}, []string{}
+	assert.Equal(t, msg, got)
+
+	var generic map[string]any
+	assert.Nil(t, Unmarshal(raw, &generic))
+	assert.Equal(t, int64(-5), generic["z"])
+	assert.Equal(t, map[string]any{"a": int64(1), "b": int64(2)}, generic["attrs"])
+
+	// omitempty is found among other options
+	msg.Count = 3
+	raw, _ = Marshal(&msg)
+	assert.Nil(t, Unmarshal(raw, &generic))
+	assert.Equal(t, int64(3), generic["cnt"])
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := Marshal(42)
+	assert.NotNil(t, err)
+	_, err = Marshal(map[int]int{1: 1})
+	var ue *UnsupportedTypeError
+	assert.True(t, errors.As(err, &ue))
+	_, err = Marshal(struct{ C chan int }{})
+	assert.True(t, errors.As(err, &ue))
+	_, err = Marshal(struct {
+		A int `binson:"x"`
+		B int `binson:"x"`
+	}{})
+	assert.NotNil(t, err)
+
+	raw, _ := Marshal(map[string]any{"a": "text", "b": 300})
+	var wrongType struct {
+		A int `binson:"a"`
+	}
+	err = Unmarshal(raw, &wrongType)
+	var te *TypeError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, "a", te.Path)
+
+	var overflow struct {
+		B int8 `binson:"b"`
+	}
+	assert.NotNil(t, Unmarshal(raw, &overflow))
+	assert.NotNil(t, Unmarshal(raw, overflow))
+	assert.NotNil(t, Unmarshal(append(raw, 0), &map[string]any{}))
+	assert.True(t, errors.Is(Unmarshal(raw[:5], &map[string]any{}), ErrUnexpectedEOF))
+}
+
+// deviceID is encoded as BYTES, but held as a hex string
+type deviceID string
+
+func (id deviceID) MarshalBinson(e *Encoder) error {
+	b, err := hex.DecodeString(string(id))
+	if err != nil {
+		return err
+	}
+	e.Bytes(b)
+	return nil
+}
+
+func (id *deviceID) UnmarshalBinson(d *Decoder) error {
+	b, err := d.BytesValue()
+	if err != nil {
+		return err
+	}
+	*id = deviceID(hex.EncodeToString(b))
+	return nil
+}
+
+// point is encoded as the array [x, y], and only its x is decoded
+type point struct {
+	X, Y int64
+}
+
+func (p *point) MarshalBinson(e *Encoder) error {
+	e.BeginArray()
+	e.Integer(p.X)
+	e.Integer(p.Y)
+	e.EndArray()
+	return nil
+}
+
+func (p *point) UnmarshalBinson(d *Decoder) error {
+	d.GoIntoArray()
+	d.NextArrayValue()
+	p.X, _ = d.Int()
+	return d.Err()
+}
+
+func TestMarshaler(t *testing.T) {
+	type device struct {
+		ID     deviceID    `binson:"id"`
+		IDs    []deviceID  `binson:"ids"`
+		Pos    point       `binson:"pos"`
+		Extra  ObjectValue `binson:"x"`
+		Single Value       `binson:"y"`
+	}
+	var dev = device{
+		ID:     "cafe",
+		IDs:    []deviceID{"01", "02"},
+		Pos:    point{X: 3, Y: 4},
+		Extra:  ObjectValue{"b": IntValue(1), "a": StringValue("s")},
+		Single: ArrayValue{BoolValue(true)},
+	}
+
+	raw, err := Marshal(&dev)
+	assert.Nil(t, err)
+	var d = NewBytesDecoder(raw)
+	d.Field("id")
+	assert.Equal(t, []byte{0xca, 0xfe}, d.Value)
+	d.Field("pos")
+	assert.Equal(t, Array, d.ValueType)
+
+	var got device
+	assert.Nil(t, Unmarshal(raw, &got))
+	dev.Pos.Y = 0
+	assert.Equal(t, dev, got)
+
+	// ObjectValue encodes itself at the top-level too
+	raw2, err := Marshal(dev.Extra)
+	assert.Nil(t, err)
+	var obj ObjectValue
+	assert.Nil(t, Unmarshal(raw2, &obj))
+	assert.Equal(t, dev.Extra, obj)
+
+	_, err = Marshal(map[string]deviceID{"id": "not hex"})
+	assert.NotNil(t, err)
+
+	// conversion to and from ObjectValue goes through the Marshaler
+	obj, err = ToObject(&dev)
+	assert.Nil(t, err)
+	assert.Equal(t, BytesValue{0xca, 0xfe}, obj["id"])
+	assert.Equal(t, ArrayValue{IntValue(3), IntValue(0)}, obj["pos"])
+	got = device{}
+	obj["id"] = BytesValue{0xbe, 0xef}
+	assert.Nil(t, FromObject(obj, &got))
+	assert.Equal(t, deviceID("beef"), got.ID)
+	assert.Equal(t, int64(3), got.Pos.X)
+
+	_, err = ToObject(map[string]deviceID{"id": "not hex"})
+	assert.NotNil(t, err)
+	assert.NotNil(t, FromObject(obj, got))
+
+	// the fields written by a Marshaler need not be sorted
+	obj, err = ToObject(unsorted{})
+	assert.Nil(t, err)
+	assert.Equal(t, ObjectValue{"b": IntValue(2), "a": IntValue(1)}, obj)
+}
+
+// unsorted writes its fields out of Binson sort order
+type unsorted struct{}
+
+func (unsorted) MarshalBinson(e *Encoder) error {
+	e.Begin()
+	e.Name("b")
+	e.Integer(2)
+	e.Name("a")
+	e.Integer(1)
+	e.End()
+	return nil
+}
+
+func TestUnmarshalerTopLevel(t *testing.T) {
+	raw, _ := Marshal(map[string]int{"a": 1, "b": 2})
+
+	// the rest of the object is skipped after UnmarshalBinson
+	var f firstField
+	assert.Nil(t, Unmarshal(raw, &f))
+	assert.Equal(t, firstField("a"), f)
+}
+
+type firstField string
+
+func (f *firstField) UnmarshalBinson(d *Decoder) error {
+	d.NextField()
+	*f = firstField(d.Name)
+	return d.Err()
+}
@@ -0,0 +1,159 @@
+package binson
+
+import "fmt"
+
+// PeekType returns the type of the next field or array value, the one
+// that NextField or NextArrayValue would return, without reading it.
+// At the start of input it returns Object for the top-level object.
+// An OBJECT/ARRAY value that was not entered is skipped first, like
+// NextField and NextArrayValue would do. Returns false if the current
+// container has no more values or an error occurred (see Err).
+func (d *Decoder) PeekType() (ValueType, bool) {
+	if d.err != nil {
+		return 0, false
+	}
+
+	switch d.state {
+	case stateZero:
+		return d.peekType(sigBegin)
+	case stateBeforeObject, stateBeforeArray:
+		d.skipContainer(d.ValueType)
+		d.stateAfterValue()
+		return d.PeekType()
+	case stateBeforeArrayValue:
+		return d.peekType(sigEndArray)
+	case stateBeforeField:
+		if !d.peekName() {
+			return 0, false
+		}
+		fallthrough
+	case stateFieldPending:
+		return d.peekType(0)
+	case stateEndOfObject, stateEndOfArray:
+		return 0, false
+	default:
+		d.fail(fmt.Errorf("%w: cannot peek, state: %v", ErrInvalidState, d.state))
+		return 0, false
+	}
+}
+
+// PeekName returns the name of the next field of the current object,
+// the one that NextField would return, without reading it: the Name field,
+// Offset and Path are left unchanged, and the field counts towards
+// MaxFields and is checked by Strict only when it is read. At the start
+// of input the BEGIN of the top-level object is consumed and the object
+// entered, like NextField does, so Offset and Depth become 1. Returns
+// false if the object has no more fields or an error occurred (see Err).
+func (d *Decoder) PeekName() (string, bool) {
+	if d.err != nil {
+		return "", false
+	}
+
+	switch d.state {
+	case stateZero:
+		d.parseBegin()
+		return d.PeekName()
+	case stateBeforeObject, stateBeforeArray:
+		if len(d.stack) == 0 || d.stack[len(d.stack)-1].kind != Object {
+			break
+		}
+		d.skipContainer(d.ValueType)
+		d.state = stateBeforeField
+		return d.PeekName()
+	case stateBeforeField:
+		if !d.peekName() {
+			return "", false
+		}
+		return string(d.nameBuf), true
+	case stateFieldPending:
+		return string(d.nameBuf), true
+	case stateEndOfObject:
+		return "", false
+	}
+	d.fail(fmt.Errorf("%w: not before a field, state: %v", ErrInvalidState, d.state))
+	return "", false
+}
+
+/* === private methods === */
+
+// peekName reads the next field name ahead into nameBuf, returns false
+// at the end of the object. The name is counted and, in strict mode,
+// checked when the field is read, until then Offset and Path leave it out.
+func (d *Decoder) peekName() bool {
+	start := d.off
+	sig, ok := d.readByte()
+	if !ok {
+		return false
+	}
+	if sig == sigEnd {
+		d.unreadByte(sig)
+		return false
+	}
+	if !d.checkNameSig(sig) || !d.readNameBytes(sig) {
+		return false
+	}
+	d.ahead = d.off - start
+	d.state = stateFieldPending
+	return true
+}
+
+// peekType reads the next signature byte ahead and returns its type,
+// end is the signature that ends the current container, if any.
+// The top-level object is recognized by passing sigBegin as end.
+func (d *Decoder) peekType(end byte) (ValueType, bool) {
+	sig, ok := d.readByte()
+	if !ok {
+		return 0, false
+	}
+
+	var t ValueType
+	switch {
+	case end == sigBegin && sig != sigBegin:
+		d.failSyntax("expected BEGIN, got: 0x%02x", sig)
+		return 0, false
+	case sig == end && end != sigBegin:
+		d.unreadByte(sig)
+		return 0, false
+	default:
+		if t, ok = typeOf(sig); !ok {
+			d.failSyntax("unexpected type byte: 0x%02x", sig)
+			return 0, false
+		}
+	}
+	d.unreadByte(sig)
+	return t, true
+}
+
+// unreadByte pushes back the byte just returned by readByte.
+func (d *Decoder) unreadByte(b byte) {
+	d.off--
+	if d.mem {
+		return
+	}
+	d.peeked, d.peekSig = true, b
+	if d.capturing {
+		d.capture = d.capture[:len(d.capture)-1]
+	}
+}
+
+// typeOf returns the type of the value starting with signature sig.
+func typeOf(sig byte) (ValueType, bool) {
+	switch sig {
+	case sigBegin:
+		return Object, true
+	case sigBeginArray:
+		return Array, true
+	case sigFalse, sigTrue:
+		return Boolean, true
+	case sigDouble:
+		return Double, true
+	case sigInteger1, sigInteger2, sigInteger4, sigInteger8:
+		return Integer, true
+	case sigString1, sigString2, sigString4:
+		return String, true
+	case sigBytes1, sigBytes2, sigBytes4:
+		return Bytes, true
+	default:
+		return 0, false
+	}
+}
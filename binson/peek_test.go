@@ -0,0 +1,117 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderPeek(t *testing.T) {
+	// {"a":[1,"x",{"b":2},[]],"c":true}
+	var raw = []byte("\x40\x14\x01\x61\x42\x10\x01\x14\x01\x78\x40\x14\x01\x62\x10\x02\x41\x42\x43\x43\x14\x01\x63\x44\x41")
+
+	for _, d := range []*Decoder{NewBytesDecoder(raw), NewDecoder(bytes.NewReader(raw))} {
+		vt, ok := d.PeekType()
+		assert.Equal(t, true, ok)
+		assert.Equal(t, Object, vt)
+		assert.Equal(t, int64(0), d.Offset())
+
+		name, ok := d.PeekName()
+		assert.Equal(t, true, ok)
+		assert.Equal(t, "a", name)
+		assert.Equal(t, "", d.Name)
+		vt, _ = d.PeekType()
+		assert.Equal(t, Array, vt)
+		assert.Equal(t, int64(1), d.Offset())
+		assert.Equal(t, "", d.Path())
+		assert.Equal(t, 1, d.Depth())
+
+		assert.Equal(t, true, d.NextField())
+		assert.Equal(t, "a", d.Name)
+		assert.Equal(t, int64(5), d.Offset())
+		assert.Equal(t, "a", d.Path())
+		d.GoIntoArray()
+
+		var types []ValueType
+		for {
+			vt, ok := d.PeekType()
+			if !ok {
+				break
+			}
+			types = append(types, vt)
+			assert.Equal(t, true, d.NextArrayValue())
+			assert.Equal(t, vt, d.ValueType)
+		}
+		assert.Equal(t, []ValueType{Integer, String, Object, Array}, types)
+		assert.Equal(t, false, d.NextArrayValue())
+		d.GoUpToObject()
+
+		var off = d.Offset()
+		name, _ = d.PeekName()
+		assert.Equal(t, "c", name)
+		assert.Equal(t, off, d.Offset())
+		assert.Equal(t, "a", d.Path())
+		assert.Equal(t, true, d.Field("c"))
+		assert.Equal(t, off+4, d.Offset())
+		assert.Equal(t, "c", d.Path())
+		assert.Equal(t, true, d.Value)
+		_, ok = d.PeekName()
+		assert.Equal(t, false, ok)
+		_, ok = d.PeekType()
+		assert.Equal(t, false, ok)
+		assert.Equal(t, false, d.NextField())
+		assert.Nil(t, d.Err())
+	}
+}
+
+func TestDecoderPeekToken(t *testing.T) {
+	// {"a":1}
+	var raw = []byte("\x40\x14\x01\x61\x10\x01\x41")
+
+	var d = NewDecoder(bytes.NewReader(raw))
+	d.PeekType()
+	tok, err := d.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, TokenBeginObject, tok.Kind)
+	d.PeekType()
+	tok, _ = d.Token()
+	assert.Equal(t, Token{Kind: TokenName, Name: "a"}, tok)
+	tok, _ = d.Token()
+	assert.Equal(t, Token{Kind: TokenValue, ValueType: Integer, Value: int64(1)}, tok)
+
+	d = NewBytesDecoder([]byte("\x40\x14\x01\x61\x99"))
+	d.PeekName()
+	_, ok := d.PeekType()
+	assert.Equal(t, false, ok)
+	var se *SyntaxError
+	assert.True(t, errors.As(d.Err(), &se))
+	assert.Equal(t, int64(4), se.Offset)
+}
+
+func TestDecoderPeekStrict(t *testing.T) {
+	// {"b":1,"a":2}, fields out of order
+	var raw = []byte("\x40\x14\x01\x62\x10\x01\x14\x01\x61\x10\x02\x41")
+
+	var d = NewBytesDecoderWithOptions(raw, DecoderOptions{Strict: true, MaxFields: 1})
+	assert.Equal(t, true, d.NextField())
+	name, ok := d.PeekName()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "a", name)
+	assert.Nil(t, d.Err())
+	assert.Equal(t, int64(6), d.Offset())
+	assert.Equal(t, "b", d.Path())
+
+	// the field is checked when it is read
+	assert.Equal(t, false, d.NextField())
+	assert.Equal(t, LimitFields, limitKindOf(d.Err()))
+
+	d = NewBytesDecoderWithOptions(raw, DecoderOptions{Strict: true})
+	d.NextField()
+	d.PeekType()
+	_, err := d.Token()
+	var se *SyntaxError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, int64(9), se.Offset)
+}
@@ -0,0 +1,139 @@
+package binson
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// RawValue is an encoded Binson value, e.g. as returned by Decoder.RawValue.
+// It can be written back verbatim with Encoder.RawValue.
+type RawValue []byte
+
+// RawValue returns the exact encoded bytes of the current value. If the
+// current value is an OBJECT or ARRAY that has not been entered, it is
+// consumed including all nested values, like NextField would skip it.
+// At the start of input the whole top-level object is returned.
+//
+// For a decoder created with NewBytesDecoder the result is a sub-slice of
+// the input, otherwise it is only valid until the next call to RawValue.
+func (d *Decoder) RawValue() ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if d.pending > 0 && !d.streamed {
+		d.loadBytes()
+	}
+
+	var start = d.off
+	d.capture = d.capture[:0]
+	d.capturing = !d.mem
+
+	switch d.state {
+	case stateZero:
+		d.ValueType = Object
+		d.Skip()
+	case stateBeforeFieldValue:
+		if sig, ok := d.readByte(); ok {
+			d.parseValue(sig, stateBeforeField)
+			if d.pending > 0 {
+				d.loadBytes() // captured, like the rest of the value
+			}
+			d.Skip()
+		}
+	case stateBeforeObject, stateBeforeArray:
+		start = d.off - 1
+		d.capture = append(d.capture, d.sigByte)
+		d.Skip()
+	default:
+		d.capturing = false
+		if d.ValueType == Object || d.ValueType == Array || d.valEnd != d.off {
+			d.fail(fmt.Errorf("%w: no current value", ErrInvalidState))
+			return nil, d.err
+		}
+		if d.streamed {
+			return nil, fmt.Errorf("%w: BYTES value already read by BytesReader", ErrInvalidState)
+		}
+		if d.mem {
+			return d.data[d.valStart:d.off:d.off], nil
+		}
+		return d.appendScalar(d.capture), nil
+	}
+
+	d.capturing = false
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.mem {
+		return d.data[start:d.off:d.off], nil
+	}
+	return d.capture, nil
+}
+
+// RawValue writes the already encoded value v verbatim to output stream
+func (e *Encoder) RawValue(v RawValue) {
+	var ok bool
+	switch {
+	case len(v) == 0:
+		if e.err == nil {
+			e.err = fmt.Errorf("%w: empty raw value", ErrInvalidNesting)
+		}
+	case v[0] == sigBegin:
+		ok = e.object()
+	default:
+		ok = e.inner()
+	}
+	if ok {
+		e.write(v)
+	}
+}
+
+// Raw writes the encoded value b verbatim to output stream, in place of a
+// value, after checking that b is exactly one valid Binson value (see
+// DecoderOptions.Strict). If it is not, nothing is written and the error,
+// with offsets relative to b, is recorded by the encoder and returned.
+func (e *Encoder) Raw(b []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := checkValue(b); err != nil {
+		e.err = err
+		return err
+	}
+	e.RawValue(b)
+	return e.err
+}
+
+/* === private methods === */
+
+// checkValue returns an error unless b is exactly one valid Binson value.
+func checkValue(b []byte) error {
+	d := NewBytesDecoderWithOptions(b, DecoderOptions{Strict: true, MaxValueLength: int64(len(b))})
+	if sig, ok := d.readByte(); ok {
+		d.skipValue(sig)
+	}
+	if d.err == nil && d.off != int64(len(b)) {
+		d.fail(&SyntaxError{msg: "trailing data after value", Offset: d.off})
+	}
+	return d.err
+}
+
+// appendScalar appends the encoding of the current boolean/integer/double/
+// string/bytes value, using the same sizes as the input did.
+func (d *Decoder) appendScalar(dst []byte) []byte {
+	dst = append(dst, d.sigByte)
+	switch d.ValueType {
+	case Double:
+		dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(d.floatVal))
+	case Integer:
+		dst = appendSized(dst, d.sigByte&intLengthMask, d.intVal)
+	case String:
+		dst = appendSized(dst, d.sigByte&intLengthMask, int64(len(d.strVal)))
+		dst = append(dst, d.strVal...)
+	case Bytes:
+		dst = appendSized(dst, d.sigByte&intLengthMask, int64(len(d.bytesVal)))
+		dst = append(dst, d.bytesVal...)
+	}
+	return dst
+}
@@ -0,0 +1,109 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderRawValue(t *testing.T) {
+	// {"a":{"b":[1,"x"]},"c":<int16 5>,"d":"hi"}
+	var raw = []byte(
+		"\x40\x14\x01\x61\x40\x14\x01\x62\x42\x10\x01\x14\x01\x78\x43\x41" +
+			"\x14\x01\x63\x11\x05\x00\x14\x01\x64\x14\x02\x68\x69\x41",
+	)
+
+	for _, d := range []*Decoder{NewBytesDecoder(raw), NewDecoder(bytes.NewReader(raw))} {
+		assert.Equal(t, true, d.Field("a"))
+		val, err := d.RawValue()
+		assert.Nil(t, err)
+		assert.Equal(t, raw[4:16], val)
+		assert.Equal(t, "a", d.Name)
+		assert.Equal(t, Object, d.ValueType)
+
+		assert.Equal(t, true, d.NextField())
+		assert.Equal(t, "c", d.Name)
+		val, err = d.RawValue()
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("\x11\x05\x00"), val)
+
+		tok, err := d.Token()
+		assert.Nil(t, err)
+		assert.Equal(t, "d", tok.Name)
+		val, err = d.RawValue()
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("\x14\x02\x68\x69"), val)
+
+		assert.Equal(t, false, d.NextField())
+		_, err = d.RawValue()
+		assert.True(t, errors.Is(err, ErrInvalidState))
+	}
+
+	for _, d := range []*Decoder{NewBytesDecoder(raw), NewDecoder(bytes.NewReader(raw))} {
+		val, err := d.RawValue()
+		assert.Nil(t, err)
+		assert.Equal(t, raw, val)
+	}
+}
+
+func TestEncoderRawValue(t *testing.T) {
+	// {"a":{"b":[1,"x"]},"c":3} -> {"x":{"b":[1,"x"]}}
+	var d = NewBytesDecoder([]byte("\x40\x14\x01\x61\x40\x14\x01\x62\x42\x10\x01\x14\x01\x78\x43\x41\x14\x01\x63\x10\x03\x41"))
+	d.Field("a")
+	val, err := d.RawValue()
+	assert.Nil(t, err)
+
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin()
+	e.Name("x")
+	e.RawValue(val)
+	e.End()
+	e.Flush()
+
+	assert.Nil(t, e.Err())
+	assert.Equal(t, []byte("\x40\x14\x01\x78\x40\x14\x01\x62\x42\x10\x01\x14\x01\x78\x43\x41\x41"), b.Bytes())
+}
+
+func TestEncoderRaw(t *testing.T) {
+	var descriptor = []byte("\x40\x14\x01\x61\x10\x01\x14\x01\x62\x42\x44\x43\x41") // {"a":1,"b":[true]}
+
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin()
+	e.Name("d")
+	assert.Nil(t, e.Raw(descriptor))
+	e.Name("n")
+	assert.Nil(t, e.Raw([]byte("\x10\x05")))
+	e.End()
+	assert.Nil(t, e.Done())
+	e.Flush()
+	assert.Equal(t, "\x40\x14\x01\x64"+string(descriptor)+"\x14\x01\x6e\x10\x05\x41", b.String())
+
+	var invalid = [][]byte{
+		nil,
+		[]byte("\x10\x05\x10\x05"), // two values
+		[]byte("\x11\x05\x00"),     // not minimal
+		[]byte("\x40\x14\x01\x62\x44\x14\x01\x61\x44\x41"), // unsorted
+		[]byte("\x42\x44"),     // truncated
+		[]byte("\x14\x01\xff"), // invalid UTF-8
+		[]byte("\x99"),
+	}
+	for _, raw := range invalid {
+		b.Reset()
+		e.Reset(&b)
+		e.Begin()
+		e.Name("a")
+		assert.NotNil(t, e.Raw(raw), "%x", raw)
+		assert.NotNil(t, e.Err(), "%x", raw)
+		e.Flush()
+		assert.Equal(t, []byte("\x40\x14\x01\x61"), b.Bytes(), "%x", raw)
+	}
+
+	// a valid value is still subject to the nesting checks
+	e.Reset(&b)
+	e.Begin()
+	assert.True(t, errors.Is(e.Raw([]byte("\x44")), ErrInvalidNesting))
+}
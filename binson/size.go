@@ -0,0 +1,48 @@
+package binson
+
+// NewSizeEncoder returns an encoder that writes nowhere, it only counts
+// the bytes of the values written, see Len. This gives the size of an
+// encoding without holding it in memory.
+func NewSizeEncoder() *Encoder {
+	e := &Encoder{}
+	e.base = &e.size
+	e.out = e.base
+	return e
+}
+
+// Len returns the number of bytes counted by a size-counting encoder, see
+// NewSizeEncoder. Fields of an object begun with BeginSorted are counted
+// at its End. Other encoders return 0.
+func (e *Encoder) Len() int {
+	return int(e.size)
+}
+
+// Size returns the length of the Binson encoding of v, as returned by
+// Marshal, without encoding it into memory.
+func Size(v any) (int, error) {
+	var e = NewSizeEncoder()
+	if err := marshal(e, v); err != nil {
+		return 0, err
+	}
+	return e.Len(), nil
+}
+
+/* === private types === */
+
+// sizeCounter is the output of a size-counting encoder
+type sizeCounter int64
+
+func (c *sizeCounter) Write(p []byte) (int, error) {
+	*c += sizeCounter(len(p))
+	return len(p), nil
+}
+
+func (c *sizeCounter) WriteByte(byte) error {
+	*c++
+	return nil
+}
+
+func (c *sizeCounter) WriteString(s string) (int, error) {
+	*c += sizeCounter(len(s))
+	return len(s), nil
+}
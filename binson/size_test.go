@@ -0,0 +1,69 @@
+package binson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeEncoder(t *testing.T) {
+	var e = NewSizeEncoder()
+	e.Begin()
+	e.Name("a")
+	e.Integer(300)
+	e.Name("b")
+	e.Double(1)
+	e.Name("c")
+	e.String(strings.Repeat("x", 200))
+	e.Name("d")
+	e.BeginArray()
+	e.Bool(true)
+	e.Bytes(make([]byte, 70000))
+	e.EndArray()
+	e.End()
+	assert.Nil(t, e.Done())
+	e.Flush()
+	// 2 object, 4*3 names, 3 int, 9 double, 3+200 string, 2 array, 1 bool, 5+70000 bytes
+	assert.Equal(t, 2+12+3+9+203+2+1+70005, e.Len())
+
+	e.Reset(nil)
+	assert.Equal(t, 0, e.Len())
+	e.BeginSorted()
+	e.Name("b")
+	e.Integer(1)
+	assert.Equal(t, 0, e.Len())
+	e.End()
+	assert.Equal(t, 7, e.Len())
+
+	e.Reset(nil)
+	e.Name("a")
+	assert.True(t, errors.Is(e.Err(), ErrInvalidNesting))
+}
+
+func TestSize(t *testing.T) {
+	var values = []any{
+		&marshalMsg{
+			Zeta:  1 << 40,
+			Data:  make([]byte, 300),
+			Inner: &marshalInner{ID: 7, Tags: []string{"x"}},
+			Attrs: map[string]int8{"b": 2, "a": 1},
+			Any:   []any{1.5, "s"},
+		},
+		ObjectValue{"n": IntValue(-129), "o": ObjectValue{"s": StringValue("ä")}},
+		map[string]string{},
+	}
+	for _, v := range values {
+		raw, err := Marshal(v)
+		assert.Nil(t, err)
+		n, err := Size(v)
+		assert.Nil(t, err)
+		assert.Equal(t, len(raw), n, "%T", v)
+	}
+
+	_, err := Size(42)
+	assert.NotNil(t, err)
+	_, err = Size(ObjectValue{"a": nil})
+	assert.NotNil(t, err)
+}
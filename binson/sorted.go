@@ -0,0 +1,85 @@
+package binson
+
+import (
+	"fmt"
+	"sort"
+)
+
+// BeginSorted writes OBJECT begin signature like Begin, but the fields of
+// the object may be written in any order: they are buffered in memory and
+// written sorted by name at the matching End. Objects begun inside it are
+// sorted too. Writing the same name twice records an error wrapping
+// ErrDuplicateName.
+func (e *Encoder) BeginSorted() {
+	if e.object() {
+		e.beginSorted()
+	}
+}
+
+/* === private methods === */
+
+// sortedField is a field of a sorted object, buffered in sortBuf
+type sortedField struct {
+	name       string
+	start, end int
+}
+
+func (e *Encoder) beginSorted() {
+	if e.sorting == 0 {
+		e.out = &e.sortBuf
+	}
+	e.sorting++
+	e.writeByte(sigBegin)
+	e.stack = append(e.stack, encoderFrame{
+		kind:   Object,
+		sorted: true,
+		start:  e.sortBuf.Len(),
+		first:  len(e.fields),
+	})
+}
+
+// addField starts a new field of the innermost (sorted) object.
+func (e *Encoder) addField(name string) {
+	f := &e.stack[len(e.stack)-1]
+	e.endField(f)
+	e.fields = append(e.fields, sortedField{name: name, start: e.sortBuf.Len()})
+}
+
+// endField ends the last field of the sorted object f, if any.
+func (e *Encoder) endField(f *encoderFrame) {
+	if len(e.fields) > f.first {
+		e.fields[len(e.fields)-1].end = e.sortBuf.Len()
+	}
+}
+
+// endSorted sorts the fields of the sorted object f in place and writes
+// its end signature, the output is written when the outermost one ends.
+func (e *Encoder) endSorted(f encoderFrame) {
+	e.endField(&f)
+	fields := e.fields[f.first:]
+	e.fields = e.fields[:f.first]
+
+	// Go compares strings byte-wise, like Binson sorts field names
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	for i := 1; i < len(fields); i++ {
+		if fields[i].name == fields[i-1].name {
+			e.err = fmt.Errorf("%w: %q", ErrDuplicateName, fields[i].name)
+			return
+		}
+	}
+
+	buf := e.sortBuf.Bytes()
+	e.sortTmp = e.sortTmp[:0]
+	for _, field := range fields {
+		e.sortTmp = append(e.sortTmp, buf[field.start:field.end]...)
+	}
+	copy(buf[f.start:], e.sortTmp)
+	e.writeByte(sigEnd)
+
+	e.sorting--
+	if e.sorting == 0 {
+		e.out = e.base
+		e.write(e.sortBuf.Bytes())
+		e.sortBuf.Reset()
+	}
+}
@@ -0,0 +1,120 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoderBeginSorted(t *testing.T) {
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin() // output before the sorted object is not reordered
+	e.End()
+	e.BeginSorted()
+	e.Name("z")
+	e.Begin()
+	e.Name("b")
+	e.String("x")
+	e.Name("a")
+	e.BeginArray()
+	e.Begin()
+	e.Name("d")
+	e.Bool(true)
+	e.Name("c")
+	e.Bool(false)
+	e.End()
+	e.EndArray()
+	e.End()
+	e.Name("ä")
+	e.Integer(2)
+	e.Name("a")
+	e.Bytes([]byte{1})
+	e.Name("")
+	e.Begin()
+	e.End()
+	e.End()
+	assert.Nil(t, e.Done())
+	e.Flush()
+
+	var exp bytes.Buffer
+	var ex = NewEncoder(&exp)
+	ex.Begin()
+	ex.End()
+	ex.Begin()
+	ex.Name("")
+	ex.Begin()
+	ex.End()
+	ex.Name("a")
+	ex.Bytes([]byte{1})
+	ex.Name("z")
+	ex.Begin()
+	ex.Name("a")
+	ex.BeginArray()
+	ex.Begin()
+	ex.Name("c")
+	ex.Bool(false)
+	ex.Name("d")
+	ex.Bool(true)
+	ex.End()
+	ex.EndArray()
+	ex.Name("b")
+	ex.String("x")
+	ex.End()
+	ex.Name("ä")
+	ex.Integer(2)
+	ex.End()
+	ex.Flush()
+	assert.Equal(t, exp.Bytes(), b.Bytes())
+
+	// nothing is written before the sorted object ends
+	b.Reset()
+	e.BeginSorted()
+	e.Name("a")
+	e.Integer(1)
+	e.Flush()
+	assert.Equal(t, 0, b.Len())
+	e.End()
+	e.Flush()
+	assert.Equal(t, []byte("\x40\x14\x01\x61\x10\x01\x41"), b.Bytes())
+}
+
+func TestEncoderBeginSortedDuplicate(t *testing.T) {
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.BeginSorted()
+	e.Name("a")
+	e.Begin()
+	e.Name("x")
+	e.Integer(1)
+	e.Name("x")
+	e.Integer(2)
+	e.End()
+	e.End()
+	e.Flush()
+	assert.True(t, errors.Is(e.Err(), ErrDuplicateName))
+	assert.Equal(t, 0, b.Len())
+
+	// Reset discards the buffered fields
+	e.Reset(&b)
+	e.BeginSorted()
+	e.End()
+	e.Flush()
+	assert.Nil(t, e.Err())
+	assert.Equal(t, []byte("\x40\x41"), b.Bytes())
+}
+
+func TestEncoderBeginSortedNoValidation(t *testing.T) {
+	var b bytes.Buffer
+	var e = NewEncoderWithOptions(&b, EncoderOptions{NoValidation: true})
+	e.BeginSorted()
+	e.Name("b")
+	e.Integer(1)
+	e.EndArray() // ends the sorted object anyway
+	e.Name("a")
+	e.Integer(2)
+	assert.Nil(t, e.Flush())
+	assert.Equal(t, []byte("\x40\x14\x01\x62\x10\x01\x41\x14\x01\x61\x10\x02"), b.Bytes())
+}
@@ -0,0 +1,111 @@
+package binson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// BytesReader returns a reader of the current BYTES value, limited to its
+// declared length. With DecoderOptions.StreamBytes the payload is read
+// directly from the input, so it never has to be held in memory. The reader
+// must be used before moving on, the rest of the payload is then skipped.
+// Errors reading the input are also recorded by the decoder.
+func (d *Decoder) BytesReader() io.Reader {
+	if err := d.checkScalar(Bytes); err != nil {
+		return errorReader{err}
+	}
+	if !d.opts.StreamBytes || d.mem {
+		return bytes.NewReader(d.bytesVal)
+	}
+	if d.streamed {
+		return errorReader{fmt.Errorf("%w: BYTES value already read by BytesReader", ErrInvalidState)}
+	}
+	d.streamed = true
+	return &bytesReader{d: d, start: d.valStart}
+}
+
+// BytesFrom writes a BYTES value of length n, reading the
+// payload from r, to output stream. If r ends before n bytes, the error
+// recorded wraps io.ErrUnexpectedEOF, the output is then truncated.
+func (e *Encoder) BytesFrom(r io.Reader, n int64) {
+	if e.err == nil && n < 0 {
+		e.err = fmt.Errorf("binson: BytesFrom: negative length %d", n)
+	}
+	if !e.inner() {
+		return
+	}
+	e.writeIntegerOrLength(sigBytes1, n)
+	if e.err != nil {
+		return
+	}
+	k, err := io.CopyN(e.out, r, n)
+	if err == io.EOF {
+		err = fmt.Errorf("binson: BytesFrom: got %d of %d bytes: %w", k, n, io.ErrUnexpectedEOF)
+	}
+	e.err = err
+}
+
+/* === private methods === */
+
+// loadBytes reads the pending payload of the current BYTES value.
+func (d *Decoder) loadBytes() {
+	buf := make([]byte, d.pending)
+	d.pending = 0
+	if d.readFull(buf) {
+		d.bytesVal = buf
+	}
+}
+
+// skipPending consumes the rest of a BYTES payload left unread.
+func (d *Decoder) skipPending() {
+	n := d.pending
+	d.pending = 0
+	d.discard(n)
+}
+
+// bytesReader reads the payload of a BYTES value from the decoder input.
+type bytesReader struct {
+	d     *Decoder
+	start int64 // valStart of the value, the reader ends when it changes
+}
+
+func (r *bytesReader) Read(p []byte) (int, error) {
+	d := r.d
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.valStart != r.start || d.pending == 0 {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if int64(len(p)) > d.pending {
+		p = p[:d.pending]
+	}
+	if !d.need(int64(len(p))) {
+		return 0, d.err
+	}
+	n, err := d.r.Read(p)
+	d.off += int64(n)
+	d.pending -= int64(n)
+	if err == io.EOF && d.pending > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		d.failRead(err)
+		return n, d.err
+	}
+	return n, nil
+}
+
+// errorReader is returned by BytesReader when there is no BYTES value.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,151 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeStreamed returns {"a":1,"b":payload,"c":0x07}
+func encodeStreamed(payload []byte) []byte {
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin()
+	e.Name("a")
+	e.Integer(1)
+	e.Name("b")
+	e.BytesFrom(bytes.NewReader(payload), int64(len(payload)))
+	e.Name("c")
+	e.Bytes([]byte{7})
+	e.End()
+	e.Flush()
+	return b.Bytes()
+}
+
+func TestDecoderBytesReader(t *testing.T) {
+	var payload = bytes.Repeat([]byte("0123456789"), 10000)
+	var raw = encodeStreamed(payload)
+	var opts = DecoderOptions{StreamBytes: true}
+
+	// not a ByteReader, so the decoder does its own buffering
+	var d = NewDecoderWithOptions(struct{ io.Reader }{bytes.NewReader(raw)}, opts)
+	assert.Equal(t, true, d.Field("b"))
+	assert.Nil(t, d.Value)
+	var got bytes.Buffer
+	n, err := io.Copy(&got, d.BytesReader())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(payload)), n)
+	assert.Equal(t, payload, got.Bytes())
+	_, err = d.BytesValue()
+	assert.True(t, errors.Is(err, ErrInvalidState))
+	assert.Equal(t, true, d.Field("c"))
+	val, err := d.BytesValue()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{7}, val)
+	assert.Equal(t, false, d.NextField())
+	assert.Nil(t, d.Err())
+
+	// unread and partially read payloads are skipped
+	for _, read := range []int64{0, 5} {
+		d = NewDecoderWithOptions(bytes.NewReader(raw), opts)
+		d.Field("b")
+		var r = d.BytesReader()
+		n, _ := io.CopyN(io.Discard, r, read)
+		assert.Equal(t, read, n)
+		assert.Equal(t, true, d.Field("c"))
+		n, err = io.Copy(io.Discard, r)
+		assert.Equal(t, int64(0), n)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(raw)-2), d.Offset())
+		assert.Nil(t, d.Err())
+	}
+
+	// BytesValue and RawValue load the payload
+	d = NewDecoderWithOptions(bytes.NewReader(raw), opts)
+	d.Field("b")
+	val, err = d.BytesValue()
+	assert.Nil(t, err)
+	assert.Equal(t, payload, val)
+	d = NewDecoderWithOptions(bytes.NewReader(raw), opts)
+	d.Field("c")
+	rv, err := d.RawValue()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x18, 0x01, 0x07}, rv)
+
+	// also right after the name was read by Token
+	d = NewDecoderWithOptions(bytes.NewReader(raw), opts)
+	for tok, _ := d.Token(); tok.Name != "b"; tok, _ = d.Token() {
+	}
+	rv, err = d.RawValue()
+	assert.Nil(t, err)
+	assert.Equal(t, append([]byte{0x1a, 0xa0, 0x86, 0x01, 0x00}, payload...), []byte(rv))
+	assert.Equal(t, true, d.Field("c"))
+
+	// without StreamBytes the value is read from memory
+	d = NewBytesDecoderWithOptions(raw, opts)
+	d.Field("b")
+	got.Reset()
+	io.Copy(&got, d.BytesReader())
+	assert.Equal(t, payload, got.Bytes())
+
+	d = NewBytesDecoder(raw)
+	d.Field("a")
+	_, err = d.BytesReader().Read(make([]byte, 1))
+	var te *TypeError
+	assert.True(t, errors.As(err, &te))
+}
+
+func TestDecoderBytesReaderTruncated(t *testing.T) {
+	var raw = encodeStreamed(make([]byte, 1000))
+
+	var d = NewDecoderWithOptions(bytes.NewReader(raw[:500]), DecoderOptions{StreamBytes: true})
+	d.Field("b")
+	_, err := io.Copy(io.Discard, d.BytesReader())
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+	assert.Equal(t, err, d.Err())
+
+	d = NewDecoderWithOptions(bytes.NewReader(raw[:500]), DecoderOptions{StreamBytes: true})
+	assert.Equal(t, false, d.Field("c"))
+	assert.True(t, errors.Is(d.Err(), ErrUnexpectedEOF))
+}
+
+func TestDecoderStreamBytesValue(t *testing.T) {
+	var raw = encodeStreamed([]byte{4, 5, 6})
+	var opts = DecoderOptions{StreamBytes: true}
+
+	var d = NewDecoderWithOptions(bytes.NewReader(raw), opts)
+	assert.Equal(t, true, d.NextField())
+	assert.Equal(t, int64(1), d.Value)
+	assert.Equal(t, true, d.NextField())
+	assert.Equal(t, Bytes, d.ValueType)
+	assert.Nil(t, d.Value)
+	io.Copy(io.Discard, d.BytesReader())
+	_, err := d.RawValue()
+	assert.True(t, errors.Is(err, ErrInvalidState))
+
+	toks, err := collectTokens(NewDecoderWithOptions(bytes.NewReader(raw), opts))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, Token{Kind: TokenValue, ValueType: Bytes}, toks[4])
+}
+
+func TestEncoderBytesFromShort(t *testing.T) {
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	e.Begin()
+	e.Name("a")
+	e.BytesFrom(bytes.NewReader([]byte{1, 2}), 3)
+	assert.True(t, errors.Is(e.Err(), io.ErrUnexpectedEOF))
+	assert.EqualError(t, e.Err(), "binson: BytesFrom: got 2 of 3 bytes: unexpected EOF")
+
+	b.Reset()
+	e.Reset(&b)
+	e.Begin()
+	e.Name("a")
+	e.BytesFrom(bytes.NewReader(nil), -1)
+	assert.NotNil(t, e.Err())
+	e.Flush()
+	assert.Equal(t, []byte("\x40\x14\x01\x61"), b.Bytes())
+}
@@ -0,0 +1,191 @@
+package binson
+
+import (
+	"fmt"
+	"io"
+)
+
+// TokenKind is the kind of a Token
+type TokenKind uint
+
+// Token kinds enumeration
+const (
+	TokenBeginObject TokenKind = iota
+	TokenEndObject
+	TokenBeginArray
+	TokenEndArray
+	TokenName
+	TokenValue
+)
+
+var tokenKindNames = [...]string{
+	TokenBeginObject: "begin-object",
+	TokenEndObject:   "end-object",
+	TokenBeginArray:  "begin-array",
+	TokenEndArray:    "end-array",
+	TokenName:        "name",
+	TokenValue:       "value",
+}
+
+func (k TokenKind) String() string {
+	if int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", uint(k))
+}
+
+// A Token is one syntactic element of a Binson object. With
+// DecoderOptions.StreamBytes, Value is nil for a non-empty BYTES value,
+// read it with Decoder.BytesReader or Decoder.BytesValue instead.
+type Token struct {
+	Kind      TokenKind
+	Name      string      // field name, for TokenName
+	ValueType ValueType   // value type, for TokenValue
+	Value     interface{} // boolean/integer/double/string/bytes value, for TokenValue
+}
+
+// Token returns the next token of the input. After the END of the top-level
+// object it returns io.EOF, as it does when the input ends cleanly before
+// a top-level object, e.g. after the last of several objects on a stream.
+// Input ending anywhere else is an error wrapping ErrUnexpectedEOF.
+//
+// Token can be mixed with the field-oriented methods: if NextField or
+// NextArrayValue has just returned an OBJECT or ARRAY value, Token returns
+// its begin token and goes into it, and if they have returned false at an
+// end-of-object/array, Token continues in the parent.
+func (d *Decoder) Token() (Token, error) {
+	if d.err != nil {
+		return Token{}, d.err
+	}
+
+	var tok Token
+	switch d.state {
+	case stateZero:
+		if d.atEnd() {
+			return Token{}, io.EOF
+		}
+		if d.err != nil {
+			break
+		}
+		d.parseBegin()
+		tok.Kind = TokenBeginObject
+	case stateEndOfObject, stateEndOfArray:
+		if len(d.stack) == 0 {
+			return Token{}, io.EOF
+		}
+		d.stateAfterValue()
+		return d.Token()
+	case stateBeforeObject:
+		d.GoIntoObject()
+		tok.Kind = TokenBeginObject
+	case stateBeforeArray:
+		d.GoIntoArray()
+		tok.Kind = TokenBeginArray
+	case stateBeforeField:
+		tok = d.tokenInObject()
+	case stateFieldPending:
+		if !d.takeName() {
+			break
+		}
+		d.setName()
+		d.state = stateBeforeFieldValue
+		tok = Token{Kind: TokenName, Name: d.Name}
+	case stateBeforeFieldValue:
+		if sig, ok := d.readByte(); ok {
+			tok = d.tokenValue(sig, stateBeforeField)
+		}
+	case stateBeforeArrayValue:
+		tok = d.tokenInArray()
+	}
+
+	if d.err != nil {
+		return Token{}, d.err
+	}
+	return tok, nil
+}
+
+/* === private methods === */
+
+// stateAfterValue sets the state after a value of the current container
+// has been completely read
+func (d *Decoder) stateAfterValue() {
+	if d.stack[len(d.stack)-1].kind == Array {
+		d.state = stateBeforeArrayValue
+	} else {
+		d.state = stateBeforeField
+	}
+}
+
+// atEnd reports whether the input ends here, without consuming input.
+func (d *Decoder) atEnd() bool {
+	if d.peeked {
+		return false
+	}
+	if d.mem {
+		return d.off >= int64(len(d.data))
+	}
+	b, err := d.r.ReadByte()
+	if err == io.EOF {
+		return true
+	}
+	if err != nil {
+		d.failRead(err)
+		return false
+	}
+	d.off++
+	d.unreadByte(b)
+	return false
+}
+
+func (d *Decoder) tokenInObject() Token {
+	sig, ok := d.readByte()
+	if !ok {
+		return Token{}
+	}
+	if sig == sigEnd {
+		d.pop()
+		d.state = stateEndOfObject
+		if len(d.stack) > 0 {
+			d.stateAfterValue()
+		}
+		return Token{Kind: TokenEndObject}
+	}
+
+	d.parseFieldName(sig)
+	d.state = stateBeforeFieldValue
+	return Token{Kind: TokenName, Name: d.Name}
+}
+
+func (d *Decoder) tokenInArray() Token {
+	sig, ok := d.readByte()
+	if !ok {
+		return Token{}
+	}
+	if sig == sigEndArray {
+		d.pop()
+		d.stateAfterValue()
+		return Token{Kind: TokenEndArray}
+	}
+
+	if !d.countItem() {
+		return Token{}
+	}
+	return d.tokenValue(sig, stateBeforeArrayValue)
+}
+
+func (d *Decoder) tokenValue(sig byte, afterValueState int) Token {
+	d.parseValue(sig, afterValueState)
+	switch d.state {
+	case stateBeforeObject:
+		d.GoIntoObject()
+		return Token{Kind: TokenBeginObject}
+	case stateBeforeArray:
+		d.GoIntoArray()
+		return Token{Kind: TokenBeginArray}
+	}
+	tok := Token{Kind: TokenValue, ValueType: d.ValueType}
+	if d.pending == 0 {
+		tok.Value = d.boxValue()
+	}
+	return tok
+}
@@ -0,0 +1,118 @@
+package binson
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectTokens(d *Decoder) ([]Token, error) {
+	var toks []Token
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return toks, err
+		}
+		toks = append(toks, tok)
+	}
+}
+
+func TestTokenSequence(t *testing.T) {
+	// {"a":1,"b":[true,{"c":"x"},[]],"d":{}}
+	var raw = []byte(
+		"\x40\x14\x01\x61\x10\x01\x14\x01\x62\x42\x44\x40\x14\x01\x63\x14\x01\x78\x41\x42\x43\x43" +
+			"\x14\x01\x64\x40\x41\x41",
+	)
+	var exp = []Token{
+		{Kind: TokenBeginObject},
+		{Kind: TokenName, Name: "a"},
+		{Kind: TokenValue, ValueType: Integer, Value: int64(1)},
+		{Kind: TokenName, Name: "b"},
+		{Kind: TokenBeginArray},
+		{Kind: TokenValue, ValueType: Boolean, Value: true},
+		{Kind: TokenBeginObject},
+		{Kind: TokenName, Name: "c"},
+		{Kind: TokenValue, ValueType: String, Value: "x"},
+		{Kind: TokenEndObject},
+		{Kind: TokenBeginArray},
+		{Kind: TokenEndArray},
+		{Kind: TokenEndArray},
+		{Kind: TokenName, Name: "d"},
+		{Kind: TokenBeginObject},
+		{Kind: TokenEndObject},
+		{Kind: TokenEndObject},
+	}
+
+	toks, err := collectTokens(NewDecoder(bytes.NewReader(raw)))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, exp, toks)
+
+	toks, err = collectTokens(NewBytesDecoder(raw))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, exp, toks)
+}
+
+func TestTokenMixedWithNextField(t *testing.T) {
+	// {"a":1,"b":{"c":3},"d":4}
+	var d = NewBytesDecoder([]byte("\x40\x14\x01\x61\x10\x01\x14\x01\x62\x40\x14\x01\x63\x10\x03\x41\x14\x01\x64\x10\x04\x41"))
+
+	assert.Equal(t, true, d.Field("b"))
+	tok, err := d.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, TokenBeginObject, tok.Kind)
+
+	assert.Equal(t, true, d.NextField())
+	assert.Equal(t, "c", d.Name)
+	assert.Equal(t, false, d.NextField())
+
+	tok, err = d.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, Token{Kind: TokenName, Name: "d"}, tok)
+
+	// a pending field value is skipped by NextField
+	assert.Equal(t, false, d.NextField())
+	_, err = d.Token()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestTokenErrors(t *testing.T) {
+	// {"a":[1,2,3]}
+	var raw = []byte("\x40\x14\x01\x61\x42\x10\x01\x10\x02\x10\x03\x43\x41")
+
+	_, err := collectTokens(NewBytesDecoderWithOptions(raw, DecoderOptions{MaxArrayLength: 2}))
+	assert.Equal(t, LimitArrayLength, limitKindOf(err))
+
+	_, err = collectTokens(NewBytesDecoder(raw[:len(raw)-2]))
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+}
+
+func TestTokenEndOfStream(t *testing.T) {
+	// two objects back-to-back, then the end of the stream
+	var raw = []byte("\x40\x41\x40\x14\x01\x61\x44\x41")
+	for _, r := range []io.Reader{bytes.NewReader(raw), bufio.NewReader(struct{ io.Reader }{bytes.NewReader(raw)})} {
+		var d = NewDecoder(r)
+		var objects int
+		for {
+			toks, err := collectTokens(d)
+			if len(toks) == 0 {
+				assert.Equal(t, io.EOF, err)
+				break
+			}
+			assert.Equal(t, io.EOF, err)
+			objects++
+			d = NewDecoder(r)
+		}
+		assert.Equal(t, 2, objects)
+	}
+
+	toks, err := collectTokens(NewBytesDecoder(nil))
+	assert.Empty(t, toks)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = collectTokens(NewBytesDecoder([]byte("\x40\x14")))
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+}
@@ -0,0 +1,250 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// Value is a Binson value held in memory, one of ObjectValue, ArrayValue,
+// BoolValue, IntValue, DoubleValue, StringValue or BytesValue.
+type Value interface {
+	Type() ValueType
+	isValue()
+}
+
+// ObjectValue is a Binson OBJECT held in memory. The fields are always
+// encoded in Binson sort order, whatever order they were added in.
+type ObjectValue map[string]Value
+
+// ArrayValue is a Binson ARRAY held in memory.
+type ArrayValue []Value
+
+// BoolValue is a Binson BOOLEAN held in memory.
+type BoolValue bool
+
+// IntValue is a Binson INTEGER held in memory.
+type IntValue int64
+
+// DoubleValue is a Binson DOUBLE held in memory.
+type DoubleValue float64
+
+// StringValue is a Binson STRING held in memory.
+type StringValue string
+
+// BytesValue is a Binson BYTES value held in memory.
+type BytesValue []byte
+
+// Type returns Object
+func (ObjectValue) Type() ValueType { return Object }
+
+// Type returns Array
+func (ArrayValue) Type() ValueType { return Array }
+
+// Type returns Boolean
+func (BoolValue) Type() ValueType { return Boolean }
+
+// Type returns Integer
+func (IntValue) Type() ValueType { return Integer }
+
+// Type returns Double
+func (DoubleValue) Type() ValueType { return Double }
+
+// Type returns String
+func (StringValue) Type() ValueType { return String }
+
+// Type returns Bytes
+func (BytesValue) Type() ValueType { return Bytes }
+
+func (ObjectValue) isValue() {}
+func (ArrayValue) isValue()  {}
+func (BoolValue) isValue()   {}
+func (IntValue) isValue()    {}
+func (DoubleValue) isValue() {}
+func (StringValue) isValue() {}
+func (BytesValue) isValue()  {}
+
+// errNilValue is returned when encoding a nil Value
+var errNilValue = errors.New("binson: nil Value")
+
+// Parse decodes the Binson object in b. The input must be valid Binson,
+// see DecoderOptions.Strict. BYTES values are copied, the result does not
+// refer to b.
+func Parse(b []byte) (ObjectValue, error) {
+	var obj ObjectValue
+	err := obj.UnmarshalBinary(b)
+	return obj, err
+}
+
+// MarshalBinary returns the Binson encoding of o.
+func (o ObjectValue) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	var e = NewEncoder(&b)
+	if err := o.MarshalBinson(e); err != nil {
+		return nil, err
+	}
+	if err := e.Done(); err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the Binson object in b into o, replacing its
+// contents, see Parse.
+func (o *ObjectValue) UnmarshalBinary(b []byte) error {
+	var d = NewBytesDecoderWithOptions(b, DecoderOptions{Strict: true, NoValue: true})
+	return o.UnmarshalBinson(d)
+}
+
+// MarshalBinson writes o with the fields sorted to e.
+func (o ObjectValue) MarshalBinson(e *Encoder) error {
+	return encodeValue(e, o)
+}
+
+// UnmarshalBinson reads the current OBJECT value of d into o,
+// replacing its contents.
+func (o *ObjectValue) UnmarshalBinson(d *Decoder) error {
+	if err := d.expect(Object); err != nil {
+		return err
+	}
+	v, err := decodeValue(d)
+	if err != nil {
+		return err
+	}
+	*o = v.(ObjectValue)
+	return nil
+}
+
+/* === private functions === */
+
+// decodeValue reads the current value of d, at the start of input the
+// top-level object.
+func decodeValue(d *Decoder) (Value, error) {
+	return decodeTree[Value, ObjectValue, ArrayValue](d, d.scalarValue)
+}
+
+// decodeTree reads the current value of d, at the start of input the
+// top-level object, into objects of type O and arrays of type A holding
+// the values returned by scalar. Nesting is tracked on a stack, not by
+// recursion, so deep input cannot exhaust the goroutine stack.
+func decodeTree[T any, O ~map[string]T, A ~[]T](d *Decoder, scalar func() T) (T, error) {
+	var zero T
+	switch d.state {
+	case stateZero, stateBeforeObject, stateBeforeArray:
+	default:
+		if err := d.currentScalar(); err != nil {
+			return zero, err
+		}
+		return scalar(), nil
+	}
+
+	type level struct {
+		obj  O
+		arr  A
+		name string
+	}
+	var stack []level
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return zero, err
+		}
+
+		var v T
+		switch tok.Kind {
+		case TokenBeginObject:
+			stack = append(stack, level{obj: O{}})
+			continue
+		case TokenBeginArray:
+			stack = append(stack, level{arr: A{}})
+			continue
+		case TokenName:
+			stack[len(stack)-1].name = tok.Name
+			continue
+		case TokenEndObject:
+			v = any(stack[len(stack)-1].obj).(T)
+			stack = stack[:len(stack)-1]
+		case TokenEndArray:
+			v = any(stack[len(stack)-1].arr).(T)
+			stack = stack[:len(stack)-1]
+		default:
+			if err := d.currentScalar(); err != nil {
+				return zero, err
+			}
+			v = scalar()
+		}
+
+		if len(stack) == 0 {
+			return v, nil
+		}
+		top := &stack[len(stack)-1]
+		if top.obj != nil {
+			top.obj[top.name] = v
+		} else {
+			top.arr = append(top.arr, v)
+		}
+	}
+}
+
+// scalarValue returns the current boolean/integer/double/string/bytes
+// value, BYTES are copied.
+func (d *Decoder) scalarValue() Value {
+	switch d.ValueType {
+	case Boolean:
+		return BoolValue(d.boolVal)
+	case Integer:
+		return IntValue(d.intVal)
+	case Double:
+		return DoubleValue(d.floatVal)
+	case String:
+		return StringValue(d.strVal)
+	default:
+		return BytesValue(append([]byte{}, d.bytesVal...))
+	}
+}
+
+func encodeValue(e *Encoder, v Value) error {
+	switch v := v.(type) {
+	case ObjectValue:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		// Go compares strings byte-wise, like Binson sorts field names
+		sort.Strings(names)
+
+		e.Begin()
+		for _, name := range names {
+			e.Name(name)
+			if err := encodeValue(e, v[name]); err != nil {
+				return err
+			}
+		}
+		e.End()
+	case ArrayValue:
+		e.BeginArray()
+		for _, item := range v {
+			if err := encodeValue(e, item); err != nil {
+				return err
+			}
+		}
+		e.EndArray()
+	case BoolValue:
+		e.Bool(bool(v))
+	case IntValue:
+		e.Integer(int64(v))
+	case DoubleValue:
+		e.Double(float64(v))
+	case StringValue:
+		e.String(string(v))
+	case BytesValue:
+		e.Bytes(v)
+	default:
+		return errNilValue
+	}
+	return nil
+}
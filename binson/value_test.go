@@ -0,0 +1,62 @@
+package binson
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectValueRoundTrip(t *testing.T) {
+	var obj = ObjectValue{
+		"z": IntValue(1),
+		"a": ArrayValue{BoolValue(true), StringValue("x"), ObjectValue{}, ArrayValue{}},
+		"m": ObjectValue{"b": BytesValue{1, 2}, "B": DoubleValue(1.5)},
+		"ä": IntValue(-300),
+	}
+
+	raw, err := obj.MarshalBinary()
+	assert.Nil(t, err)
+
+	// fields are written in sort order
+	var d = NewBytesDecoderWithOptions(raw, DecoderOptions{Strict: true})
+	var names []string
+	for name := range d.FieldsSeq() {
+		names = append(names, name)
+	}
+	assert.Nil(t, d.Err())
+	assert.Equal(t, []string{"a", "m", "z", "ä"}, names)
+
+	got, err := Parse(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, obj, got)
+	assert.Equal(t, Object, got.Type())
+	assert.Equal(t, Bytes, got["m"].(ObjectValue)["b"].Type())
+
+	// parsed BYTES do not refer to the input
+	raw2 := bytes.Clone(raw)
+	got, _ = Parse(raw2)
+	for i := range raw2 {
+		raw2[i] = 0
+	}
+	assert.Equal(t, obj, got)
+
+	// streamed BYTES payloads are loaded
+	got = nil
+	d = NewDecoderWithOptions(bytes.NewReader(raw), DecoderOptions{StreamBytes: true})
+	assert.Nil(t, got.UnmarshalBinson(d))
+	assert.Equal(t, obj, got)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse([]byte("\x40\x14\x01\x62\x10\x01\x14\x01\x61\x10\x01\x41"))
+	var se *SyntaxError
+	assert.True(t, errors.As(err, &se))
+
+	_, err = Parse([]byte("\x40\x14\x01\x61\x42\x10\x01"))
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+
+	_, err = ObjectValue{"a": nil}.MarshalBinary()
+	assert.NotNil(t, err)
+}